@@ -0,0 +1,131 @@
+package lq
+
+import "reflect"
+
+// anyValue boxes a value of any type so that it can be stored in a DB, which
+// requires comparable objects. Boxes are compared with anyEqual, by the value
+// they hold.
+type anyValue struct {
+	v any
+}
+
+// anyEqual compares two wrapped values without ever panicking: values of
+// non-comparable types, such as slices, maps and functions, are never equal,
+// not even to themselves.
+func anyEqual(a, b *anyValue) (eq bool) {
+	if a.v == nil || b.v == nil {
+		return a.v == nil && b.v == nil
+	}
+	ta := reflect.TypeOf(a.v)
+	if ta != reflect.TypeOf(b.v) || !ta.Comparable() {
+		return false
+	}
+
+	// Comparable types, such as structs with interface fields, may still hold
+	// non-comparable values, in which case == panics.
+	defer func() {
+		if recover() != nil {
+			eq = false
+		}
+	}()
+	return a.v == b.v
+}
+
+// AnyDB is a spatial database storing objects of heterogeneous types.
+//
+// Objects are stored as values of type any, that callbacks can then inspect
+// with a type switch. AnyDB provides the most common methods of DB. Objects
+// are excluded from queries (see FindNearestInRadius) if they have the same
+// type and are equal according to ==, with a few pitfalls:
+//   - values of non-comparable types (slices, maps, functions) are never
+//     equal, so such objects can't be excluded, not even by passing the very
+//     same value;
+//   - the same goes for values of comparable types holding non-comparable
+//     values, such as structs with an interface field holding a slice;
+//   - pointers are compared by address, not by the value they point to;
+//   - nil is only equal to nil, so passing nil excludes nil objects only.
+//
+// Storing pointers, or comparable identifiers, avoids these pitfalls.
+type AnyDB struct {
+	db *DB[*anyValue]
+}
+
+// NewAnyDB creates a new database of heterogeneous objects, see NewDB for the
+// meaning of the parameters.
+func NewAnyDB(xorg, yorg, xsize, ysize float64, xdiv, ydiv int) *AnyDB {
+	db := NewDB[*anyValue](xorg, yorg, xsize, ysize, xdiv, ydiv)
+	db.SetEquals(anyEqual)
+	return &AnyDB{db: db}
+}
+
+// AnyProxy is a proxy for an object in an AnyDB.
+type AnyProxy struct {
+	p Proxy[*anyValue]
+}
+
+// Object returns the object associated to the proxy.
+func (ap *AnyProxy) Object() any {
+	return ap.p.object.v
+}
+
+// Location returns the location of the proxy, as given during the last call to
+// Attach or Update.
+func (ap *AnyProxy) Location() (x, y float64) {
+	return ap.p.Location()
+}
+
+// Seq returns the sequence number of the proxy (see Proxy.Seq).
+func (ap *AnyProxy) Seq() uint64 {
+	return ap.p.Seq()
+}
+
+// Attach attaches a new object to the database and returns a proxy object.
+func (a *AnyDB) Attach(obj any, x, y float64) *AnyProxy {
+	a.db.mustNotBeFrozen("Attach")
+	ap := &AnyProxy{p: Proxy[*anyValue]{object: &anyValue{obj}, layer: AllLayers, seq: a.db.nextSeq()}}
+	a.db.Update(&ap.p, x, y)
+	return ap
+}
+
+// Update updates the location of a proxy object in the database.
+func (a *AnyDB) Update(ap *AnyProxy, x, y float64) {
+	a.db.Update(&ap.p, x, y)
+}
+
+// Detach detaches the given proxy object from the database.
+func (a *AnyDB) Detach(ap *AnyProxy) {
+	a.db.Detach(&ap.p)
+}
+
+// Len returns the number of objects in the database.
+func (a *AnyDB) Len() int {
+	return a.db.Len()
+}
+
+// ForEachObject applies a user-supplied function to all objects in the
+// database, regardless of locality (see DB.ForEachObject).
+func (a *AnyDB) ForEachObject(f func(obj any, sqDist float64)) {
+	a.db.ForEachObject(func(obj *anyValue, sqDist float64) {
+		f(obj.v, sqDist)
+	})
+}
+
+// ForEachWithinRadius applies a user-supplied function to all objects in a
+// certain locality (see DB.ForEachWithinRadius).
+func (a *AnyDB) ForEachWithinRadius(x, y, radius float64, f func(obj any, sqDist float64)) {
+	a.db.ForEachWithinRadius(x, y, radius, func(obj *anyValue, sqDist float64) {
+		f(obj.v, sqDist)
+	})
+}
+
+// FindNearestInRadius searches the database to find the object nearest to a
+// given location yet within a given radius (see DB.FindNearestInRadius).
+//
+// See AnyDB for how ignored is compared to the objects.
+func (a *AnyDB) FindNearestInRadius(x, y, radius float64, ignored any) (any, bool) {
+	obj, found := a.db.FindNearestInRadius(x, y, radius, &anyValue{ignored})
+	if !found {
+		return nil, false
+	}
+	return obj.v, true
+}
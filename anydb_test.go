@@ -0,0 +1,96 @@
+package lq
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAnyDB(t *testing.T) {
+	type named struct{ name string }
+	type holder struct{ v any }
+
+	db := NewAnyDB(0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)
+	db.Attach("two", 5.5, 5)
+	db.Attach(3.0, 6, 5)
+	db.Attach(named{"four"}, 5.5, 4)
+	slice := []int{5}
+	db.Attach(slice, 4.5, 5.5)
+	db.Attach(holder{[]int{6}}, 4, 4)
+	p := db.Attach(nil, 9, 9)
+	db.Attach(int64(1), 11, 5) // outside of the super-brick
+
+	got := make(map[string]bool)
+	db.ForEachWithinRadius(5, 5, 1.5, func(obj any, _ float64) {
+		switch v := obj.(type) {
+		case int:
+			got[fmt.Sprint("int ", v)] = true
+		case string:
+			got["string "+v] = true
+		case float64:
+			got[fmt.Sprint("float64 ", v)] = true
+		case named:
+			got["named "+v.name] = true
+		case []int:
+			got[fmt.Sprint("slice ", v)] = true
+		case holder:
+			got[fmt.Sprint("holder ", v.v)] = true
+		default:
+			t.Errorf("unexpected object %v (%T)", v, v)
+		}
+	})
+	want := []string{"int 1", "string two", "float64 3", "named four", "slice [5]", "holder [6]"}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("%q not found, got %v", w, got)
+		}
+	}
+
+	var tests = []struct {
+		name    string
+		x, y    float64
+		ignored any
+		want    any
+	}{
+		{"int", 5, 5, 1, "two"},
+		{"int64 isn't int", 5, 5, int64(1), 1},
+		{"string", 5.5, 5, "two", 1},
+		{"float", 6, 5, 3.0, "two"},
+		{"struct", 5.5, 4, named{"four"}, "two"},
+		{"slice", 4.5, 5.5, slice, slice}, // non-comparable values can't be excluded
+		{"holder", 4, 4, holder{[]int{6}}, holder{[]int{6}}},
+		{"nil", 9, 9, nil, nil},
+		{"nothing ignored", 11, 5, "none", int64(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, found := db.FindNearestInRadius(tt.x, tt.y, 1.5, tt.ignored)
+			if tt.want == nil {
+				if found {
+					t.Errorf("got %v, want nothing", obj)
+				}
+				return
+			}
+			if !found || fmt.Sprint(obj) != fmt.Sprint(tt.want) || fmt.Sprintf("%T", obj) != fmt.Sprintf("%T", tt.want) {
+				t.Errorf("got %v (%T), %t, want %v (%T)", obj, obj, found, tt.want, tt.want)
+			}
+		})
+	}
+
+	if p.Object() != nil {
+		t.Errorf("Object() = %v, want nil", p.Object())
+	}
+	db.Update(p, 1, 1)
+	if x, y := p.Location(); x != 1 || y != 1 {
+		t.Errorf("Location() = (%v, %v), want (1, 1)", x, y)
+	}
+	db.Detach(p)
+	n := 0
+	db.ForEachObject(func(any, float64) { n++ })
+	if n != 7 || db.Len() != 7 {
+		t.Errorf("ForEachObject visited %d objects, Len() = %d, want 7", n, db.Len())
+	}
+}
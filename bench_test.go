@@ -162,7 +162,7 @@ func BenchmarkNearestNeighbourLq1000Radius4(b *testing.B) {
 
 // ObjectsInLocality benchmarks
 
-func benchmarkObjectsInLocalityLq(b *testing.B, numPts int, radius float64) {
+func benchmarkObjectsInLocalityLq(b *testing.B, numPts int, radius float64, opts ...lq.Option) {
 	// superbrick settings
 	orgx, orgy := 0.0, 0.0
 	szx, szy := 10.0, 10.0
@@ -173,7 +173,8 @@ func benchmarkObjectsInLocalityLq(b *testing.B, numPts int, radius float64) {
 
 	// create and fill the database
 	ents := randomNEntities(b, src, numPts)
-	db := lq.NewDB[benchEntity](orgx, orgy, szx, szy, divx, divy)
+	opts = append([]lq.Option{lq.WithBounds(orgx, orgy, szx, szy), lq.WithDivisions(divx, divy)}, opts...)
+	db := lq.New[benchEntity](opts...)
 	for _, ent := range ents {
 		db.Attach(ent, ent.x, ent.y)
 	}
@@ -232,3 +233,294 @@ func BenchmarkObjectsInLocalityLq500Radius4(b *testing.B) {
 func BenchmarkObjectsInLocalityLq1000Radius4(b *testing.B) {
 	benchmarkObjectsInLocalityLq(b, 1000, 4)
 }
+
+// Brute force, below the threshold.
+func BenchmarkObjectsInLocalityLq10Radius2BruteForce(b *testing.B) {
+	benchmarkObjectsInLocalityLq(b, 10, 2, lq.WithBruteForceThreshold(16))
+}
+
+func BenchmarkObjectsInLocalityLq10Radius4BruteForce(b *testing.B) {
+	benchmarkObjectsInLocalityLq(b, 10, 4, lq.WithBruteForceThreshold(16))
+}
+
+// The search circle covers the whole super-brick.
+func BenchmarkObjectsInLocalityLq1000Radius20(b *testing.B) {
+	benchmarkObjectsInLocalityLq(b, 1000, 20)
+}
+
+// Frozen ObjectsInLocality benchmarks
+
+func benchmarkObjectsInLocalityFrozenLq(b *testing.B, numPts int, radius float64) {
+	// superbrick settings
+	orgx, orgy := 0.0, 0.0
+	szx, szy := 10.0, 10.0
+	divx, divy := 10, 10
+
+	src := rand.NewSource(seed)
+	rng := rand.New(src)
+
+	// create, fill and freeze the database
+	ents := randomNEntities(b, src, numPts)
+	db := lq.NewDB[benchEntity](orgx, orgy, szx, szy, divx, divy)
+	for _, ent := range ents {
+		db.Attach(ent, ent.x, ent.y)
+	}
+	db.Freeze()
+
+	for n := 0; n < b.N; n++ {
+		// generate random query point
+		x, y := 10*rng.Float64(), 10*rng.Float64()
+		db.ForEachWithinRadius(x, y, radius, func(_ benchEntity, _ float64) {})
+	}
+}
+
+func BenchmarkObjectsInLocalityFrozenLq10Radius2(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 10, 2)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq50Radius2(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 50, 2)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq100Radius2(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 100, 2)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq200Radius2(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 200, 2)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq500Radius2(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 500, 2)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq1000Radius2(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 1000, 2)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq10Radius4(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 10, 4)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq50Radius4(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 50, 4)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq100Radius4(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 100, 4)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq200Radius4(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 200, 4)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq500Radius4(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 500, 4)
+}
+
+func BenchmarkObjectsInLocalityFrozenLq1000Radius4(b *testing.B) {
+	benchmarkObjectsInLocalityFrozenLq(b, 1000, 4)
+}
+
+// Update benchmarks
+
+func benchmarkUpdateLq(b *testing.B, numPts int) {
+	// superbrick settings
+	const (
+		orgx, orgy = 0.0, 0.0
+		szx, szy   = 10.0, 10.0
+		divx, divy = 10, 10
+	)
+	src := rand.NewSource(seed)
+
+	// create and fill the database
+	ents := randomNEntities(b, src, numPts)
+	db := lq.NewDB[benchEntity](orgx, orgy, szx, szy, divx, divy)
+	proxies := make([]*lq.Proxy[benchEntity], numPts)
+	for i, ent := range ents {
+		proxies[i] = db.Attach(ent, ent.x, ent.y)
+	}
+
+	// precompute new locations, half of which are in the same bin
+	moved := randomNEntities(b, src, numPts)
+	for i := range moved {
+		if i%2 == 0 {
+			moved[i].x, moved[i].y = ents[i].x, ents[i].y
+		}
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		// Each iteration updates all proxies, as an animation frame would.
+		locs := moved
+		if n%2 == 1 {
+			locs = ents
+		}
+		for i, p := range proxies {
+			db.Update(p, locs[i].x, locs[i].y)
+		}
+	}
+}
+
+func BenchmarkUpdateLq100(b *testing.B) {
+	benchmarkUpdateLq(b, 100)
+}
+
+func BenchmarkUpdateLq1000(b *testing.B) {
+	benchmarkUpdateLq(b, 1000)
+}
+
+func BenchmarkUpdateLq10000(b *testing.B) {
+	benchmarkUpdateLq(b, 10000)
+}
+
+// CountWithinRadius benchmarks
+
+func benchmarkCountWithinRadius(b *testing.B, workers int) {
+	// superbrick settings
+	const (
+		orgx, orgy = 0.0, 0.0
+		szx, szy   = 10.0, 10.0
+		divx, divy = 10, 10
+	)
+	src := rand.NewSource(seed)
+
+	// create and fill the database
+	ents := randomNEntities(b, src, 1000)
+	db := lq.NewDB[benchEntity](orgx, orgy, szx, szy, divx, divy)
+	for _, ent := range ents {
+		db.Attach(ent, ent.x, ent.y)
+	}
+
+	centers := make([][2]float64, 1000)
+	for i, ent := range randomNEntities(b, src, len(centers)) {
+		centers[i] = [2]float64{ent.x, ent.y}
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		db.CountWithinRadiusConcurrent(centers, 2, workers)
+	}
+}
+
+func BenchmarkCountWithinRadiusSerial(b *testing.B) {
+	benchmarkCountWithinRadius(b, 1)
+}
+
+func BenchmarkCountWithinRadiusParallel(b *testing.B) {
+	benchmarkCountWithinRadius(b, 0)
+}
+
+// Closure and state-passing ObjectsInLocality benchmarks
+
+type localityAcc struct {
+	n      int
+	sqDist float64
+}
+
+func accumulate(_ benchEntity, sqDist float64, state any) {
+	acc := state.(*localityAcc)
+	acc.n++
+	acc.sqDist += sqDist
+}
+
+func benchmarkObjectsInLocalityAcc(b *testing.B, withState bool) {
+	// superbrick settings
+	const (
+		orgx, orgy = 0.0, 0.0
+		szx, szy   = 10.0, 10.0
+		divx, divy = 10, 10
+	)
+	src := rand.NewSource(seed)
+	rng := rand.New(src)
+
+	// create and fill the database
+	ents := randomNEntities(b, src, 1000)
+	db := lq.NewDB[benchEntity](orgx, orgy, szx, szy, divx, divy)
+	for _, ent := range ents {
+		db.Attach(ent, ent.x, ent.y)
+	}
+
+	// The state is reused across queries, the closure captures per-query
+	// variables instead.
+	acc := &localityAcc{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		x, y := 10*rng.Float64(), 10*rng.Float64()
+		if withState {
+			*acc = localityAcc{}
+			db.ForEachWithinRadiusState(x, y, 2, accumulate, acc)
+			sink += acc.sqDist
+		} else {
+			var total float64
+			db.ForEachWithinRadius(x, y, 2, func(_ benchEntity, sqDist float64) {
+				total += sqDist
+			})
+			sink += total
+		}
+	}
+}
+
+func BenchmarkObjectsInLocalityClosure(b *testing.B) {
+	benchmarkObjectsInLocalityAcc(b, false)
+}
+
+func BenchmarkObjectsInLocalityState(b *testing.B) {
+	benchmarkObjectsInLocalityAcc(b, true)
+}
+
+// Deferred attach benchmarks
+
+func benchmarkAttach100k(b *testing.B, deferred bool) {
+	// superbrick settings
+	const (
+		orgx, orgy = 0.0, 0.0
+		szx, szy   = 10.0, 10.0
+		divx, divy = 100, 100
+	)
+	ents := randomNEntities(b, rand.NewSource(seed), 100000)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		db := lq.NewDB[benchEntity](orgx, orgy, szx, szy, divx, divy)
+		if deferred {
+			d := lq.NewDeferred(db)
+			for _, ent := range ents {
+				d.Attach(ent, ent.x, ent.y)
+			}
+			d.Flush()
+		} else {
+			for _, ent := range ents {
+				db.Attach(ent, ent.x, ent.y)
+			}
+		}
+	}
+}
+
+func BenchmarkAttachEager100k(b *testing.B) {
+	benchmarkAttach100k(b, false)
+}
+
+func BenchmarkAttachDeferred100k(b *testing.B) {
+	benchmarkAttach100k(b, true)
+}
+
+// QueryContext benchmarks
+
+func BenchmarkQueryContextWithinRadius(b *testing.B) {
+	src := rand.NewSource(seed)
+	rng := rand.New(src)
+	db := lq.NewDB[benchEntity](0, 0, 10, 10, 10, 10)
+	for _, ent := range randomNEntities(b, src, 1000) {
+		db.Attach(ent, ent.x, ent.y)
+	}
+
+	var ctx lq.QueryContext[benchEntity]
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		x, y := 10*rng.Float64(), 10*rng.Float64()
+		sink = float64(len(ctx.WithinRadius(db, x, y, 2)))
+	}
+}
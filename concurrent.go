@@ -0,0 +1,148 @@
+package lq
+
+import (
+	"runtime"
+	"sync"
+)
+
+// countWithinRadius returns the number of objects within radius of (x,y).
+//
+// Contrary to ForEachWithinRadius, it never updates the query statistics, so
+// it doesn't modify the database at all.
+func (db *DB[T]) countWithinRadius(x, y, radius float64) int {
+	sqRadius := radius * radius
+	n := 0
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		if (x-cp.x)*(x-cp.x)+(y-cp.y)*(y-cp.y) < sqRadius {
+			n++
+		}
+	})
+	return n
+}
+
+// CountWithinRadiusConcurrent counts the objects within radius of each of the
+// given centers, running the queries in parallel.
+//
+// The queries are spread over workers goroutines (runtime.GOMAXPROCS(0) if
+// workers <= 0), counts[i] being the number of objects within radius of
+// centers[i]. The queries only read the database, so they don't need any
+// locking, but the database must not be modified until
+// CountWithinRadiusConcurrent returns. Query statistics (see EnableStats) are
+// not updated.
+func (db *DB[T]) CountWithinRadiusConcurrent(centers [][2]float64, radius float64, workers int) []int {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(centers) {
+		workers = len(centers)
+	}
+
+	counts := make([]int, len(centers))
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(centers); i += workers {
+				counts[i] = db.countWithinRadius(centers[i][0], centers[i][1], radius)
+			}
+		}(w)
+	}
+	wg.Wait()
+	return counts
+}
+
+// SyncDB is a database safe for concurrent use by multiple goroutines.
+//
+// Queries are run under a read lock, so they run concurrently with each
+// other, while modifications are run under a write lock. Query statistics
+// (see EnableStats) must not be enabled on the wrapped database, since they're
+// updated by queries.
+type SyncDB[T comparable] struct {
+	mu sync.RWMutex
+	db *DB[T]
+}
+
+// NewSyncDB returns a SyncDB wrapping db, which must not be used directly
+// anymore.
+func NewSyncDB[T comparable](db *DB[T]) *SyncDB[T] {
+	return &SyncDB[T]{db: db}
+}
+
+// Read calls f with a read-only view of the database, under a read lock. The
+// view must not be retained after f returns.
+func (s *SyncDB[T]) Read(f func(ro ReadOnlyDB[T])) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f(s.db.ReadOnly())
+}
+
+// Write calls f with the database, under a write lock. The database must not
+// be retained after f returns.
+func (s *SyncDB[T]) Write(f func(db *DB[T])) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(s.db)
+}
+
+// Swap atomically replaces the wrapped database with db, and returns the
+// previous one.
+//
+// Readers see either the previous dataset or the new one, never a mix of both.
+// This allows double-buffering: the next dataset is built in the background,
+// then swapped in, and the previous one can be recycled (for example with
+// DetachAll) to build the one after. db must not be used directly anymore, and
+// the returned database can be used directly again.
+func (s *SyncDB[T]) Swap(db *DB[T]) *DB[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.db
+	s.db = db
+	return old
+}
+
+// Len returns the number of objects in the database.
+func (s *SyncDB[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Len()
+}
+
+// ForEachWithinRadius applies f to all objects in a certain locality (see
+// DB.ForEachWithinRadius), under a read lock. f must not modify the database.
+func (s *SyncDB[T]) ForEachWithinRadius(x, y, radius float64, f Func[T]) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.db.ForEachWithinRadius(x, y, radius, f)
+}
+
+// FindNearestInRadius finds the object nearest to a location yet within a
+// radius (see DB.FindNearestInRadius), under a read lock.
+func (s *SyncDB[T]) FindNearestInRadius(x, y, radius float64, ignored T) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.FindNearestInRadius(x, y, radius, ignored)
+}
+
+// Attach attaches a new object to the database and returns a proxy object
+// (see DB.Attach), under a write lock.
+func (s *SyncDB[T]) Attach(t T, x, y float64) *Proxy[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Attach(t, x, y)
+}
+
+// Update updates the location of a proxy object (see DB.Update), under a
+// write lock.
+func (s *SyncDB[T]) Update(obj *Proxy[T], x, y float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db.Update(obj, x, y)
+}
+
+// Detach detaches a proxy object (see DB.Detach), under a write lock.
+func (s *SyncDB[T]) Detach(obj *Proxy[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db.Detach(obj)
+}
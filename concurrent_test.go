@@ -0,0 +1,135 @@
+package lq
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestCountWithinRadiusConcurrent(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	for i := 0; i < 200; i++ {
+		// Some objects are outside of the super-brick.
+		db.Attach(i, 12*rng.Float64()-1, 12*rng.Float64()-1)
+	}
+	centers := make([][2]float64, 50)
+	for i := range centers {
+		centers[i] = [2]float64{12*rng.Float64() - 1, 12*rng.Float64() - 1}
+	}
+
+	want := make([]int, len(centers))
+	for i, c := range centers {
+		db.ForEachWithinRadius(c[0], c[1], 1.5, func(int, float64) { want[i]++ })
+	}
+
+	for _, workers := range []int{0, 1, 3, 100} {
+		t.Run(fmt.Sprintf("workers=%d", workers), func(t *testing.T) {
+			got := db.CountWithinRadiusConcurrent(centers, 1.5, workers)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("got counts %v, want %v", got, want)
+			}
+		})
+	}
+
+	if got := db.CountWithinRadiusConcurrent(nil, 1.5, 4); len(got) != 0 {
+		t.Errorf("no centers: got counts %v, want none", got)
+	}
+}
+
+func TestSyncDBSwap(t *testing.T) {
+	// Two datasets, ids of the second one are offset by 1000.
+	const n = 100
+	build := func(offset int) *DB[int] {
+		db := NewDB[int](0, 0, 10, 10, 5, 5)
+		for i := 0; i < n; i++ {
+			db.Attach(offset+i, float64(i%10), float64(i/10))
+		}
+		return db
+	}
+	dbA, dbB := build(0), build(1000)
+	s := NewSyncDB(dbA)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				var low, high int
+				s.Read(func(ro ReadOnlyDB[int]) {
+					ro.ForEachWithinRadius(5, 5, 20, func(id int, _ float64) {
+						if id < 1000 {
+							low++
+						} else {
+							high++
+						}
+					})
+				})
+				if !(low == n && high == 0) && !(low == 0 && high == n) {
+					t.Errorf("torn read: %d objects from the first dataset, %d from the second", low, high)
+					return
+				}
+				if s.Len() != n {
+					t.Errorf("Len() = %d, want %d", s.Len(), n)
+					return
+				}
+			}
+		}()
+	}
+
+	// Swap the datasets back and forth. The previous dataset can be modified
+	// directly, the current one under lock.
+	rng := rand.New(rand.NewSource(1))
+	move := func(db *DB[int]) {
+		for _, p := range db.Proxies() {
+			db.Update(p, 10*rng.Float64(), 10*rng.Float64())
+		}
+	}
+	next := dbB
+	for i := 0; i < 200; i++ {
+		prev := s.Swap(next)
+		move(prev)
+		s.Write(move)
+		next = prev
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestSyncDBForEachOverlappingConcurrent(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.AttachSized(1, 2, 2, 0.5)
+	db.AttachSized(2, 5, 5, 1)
+	big := db.AttachSized(3, 8, 8, 3)
+	// The largest object is gone: the queries below must not update the
+	// database for that, which the race detector would report.
+	db.Detach(big)
+	s := NewSyncDB(db)
+
+	var wg sync.WaitGroup
+	counts := make([]int, 2)
+	for g := range counts {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			s.Read(func(ro ReadOnlyDB[int]) {
+				ro.ForEachOverlapping(3.5, 5, 0.6, func(int, float64) { counts[g]++ })
+			})
+		}(g)
+	}
+	wg.Wait()
+
+	for g, n := range counts {
+		if n != 1 {
+			t.Errorf("goroutine %d: got %d overlapping objects, want 1", g, n)
+		}
+	}
+}
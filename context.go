@@ -0,0 +1,43 @@
+package lq
+
+// QueryContext holds scratch buffers reused across queries, so that queries
+// returning their results don't allocate once the buffers are large enough.
+//
+// The slices returned by the methods of a QueryContext are owned by it, they
+// remain valid until the next call on the same context. A QueryContext isn't
+// safe for concurrent use: concurrent queries, for example from a pool of
+// worker goroutines, should each use their own context. The zero value is
+// ready to use.
+type QueryContext[T comparable] struct {
+	objs []T
+	heap boundedHeap[Neighbor[T]]
+}
+
+// WithinRadius returns the objects of db within a certain locality (see
+// DB.ForEachWithinRadius).
+func (ctx *QueryContext[T]) WithinRadius(db *DB[T], x, y, radius float64) []T {
+	ctx.objs = ctx.objs[:0]
+	db.ForEachWithinRadius(x, y, radius, func(obj T, _ float64) {
+		ctx.objs = append(ctx.objs, obj)
+	})
+	return ctx.objs
+}
+
+// KNearest returns the k objects of db nearest to a given location yet within
+// a given radius, sorted by increasing distance (see DB.FindKNearestReport).
+func (ctx *QueryContext[T]) KNearest(db *DB[T], x, y, radius float64, k int, ignored T) []Neighbor[T] {
+	h := &ctx.heap
+	if h.less == nil {
+		h.less = nearer[T]
+	}
+	h.k, h.elems = k, h.elems[:0]
+	db.ForEachWithinRadius(x, y, radius, func(obj T, sqDist float64) {
+		if !db.isIgnored(obj, ignored) {
+			h.push(Neighbor[T]{Object: obj, SqDist: sqDist})
+		}
+	})
+	neighbors := h.sorted()
+	// Keep the buffer for the next call.
+	h.elems = neighbors[:0]
+	return neighbors
+}
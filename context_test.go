@@ -0,0 +1,54 @@
+package lq
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestQueryContext(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	for i := 0; i < 100; i++ {
+		db.Attach(i, float64(i%10)+0.5, float64(i/10)+0.5)
+	}
+
+	var ctx QueryContext[int]
+	for _, q := range [][3]float64{{5, 5, 2}, {1, 1, 3}, {9, 2, 1.5}, {50, 50, 1}} {
+		want := make(idset)
+		db.ForEachWithinRadius(q[0], q[1], q[2], want.storeID)
+		got := ctx.WithinRadius(db, q[0], q[1], q[2])
+		if len(got) != len(want) {
+			t.Errorf("WithinRadius(%v) returned %d objects, want %d", q, len(got), len(want))
+		}
+		for _, id := range got {
+			want.assertContains(t, id)
+		}
+
+		for _, k := range []int{0, 1, 5, 200} {
+			want, _ := db.FindKNearestReport(q[0], q[1], q[2], k, 55)
+			got := ctx.KNearest(db, q[0], q[1], q[2], k, 55)
+			// Only compare distances, the order of equidistant objects
+			// being unspecified.
+			dists := func(ns []Neighbor[int]) []float64 {
+				var d []float64
+				for _, n := range ns {
+					d = append(d, n.SqDist)
+				}
+				sort.Float64s(d)
+				return d
+			}
+			if fmt.Sprint(dists(got)) != fmt.Sprint(dists(want)) {
+				t.Errorf("KNearest(%v, k=%d) = %v, want %v", q, k, got, want)
+			}
+		}
+	}
+
+	// Once the buffers are large enough, queries don't allocate.
+	allocs := testing.AllocsPerRun(100, func() {
+		ctx.WithinRadius(db, 5, 5, 3)
+		ctx.KNearest(db, 5, 5, 3, 10, -1)
+	})
+	if allocs != 0 {
+		t.Errorf("queries allocated %v times, want 0", allocs)
+	}
+}
@@ -0,0 +1,129 @@
+package lq
+
+// DeferredDB buffers the objects attached to a database, and inserts them in
+// bulk before the first query.
+//
+// When many objects are created at once, for example in a mass spawn, linking
+// them one by one into their bins jumps back and forth across the bins.
+// DeferredDB instead groups buffered objects by bin and links each group in
+// one go. Buffered objects are inserted by Flush, which is implicitly called
+// by all the other methods, except Attach and Len. DB returns the underlying
+// database, for the queries that DeferredDB doesn't provide.
+type DeferredDB[T comparable] struct {
+	db      *DB[T]
+	pending []*Proxy[T]
+}
+
+// NewDeferred returns a DeferredDB buffering the objects attached to db.
+func NewDeferred[T comparable](db *DB[T]) *DeferredDB[T] {
+	return &DeferredDB[T]{db: db}
+}
+
+// Attach buffers a new object, which will be attached to the database at the
+// next Flush, and returns its proxy object.
+//
+// The proxy shouldn't be used before it's been flushed, but for the methods of
+// the DeferredDB.
+func (d *DeferredDB[T]) Attach(t T, x, y float64) *Proxy[T] {
+	d.db.mustNotBeFrozen("Attach")
+	if d.db.oob == Reject && d.db.binIndex(x, y) < 0 {
+		panic(ErrOutOfBounds)
+	}
+	obj := &Proxy[T]{object: t, x: x, y: y, layer: AllLayers, seq: d.db.nextSeq()}
+	d.pending = append(d.pending, obj)
+	return obj
+}
+
+// Flush attaches the buffered objects to the database.
+func (d *DeferredDB[T]) Flush() {
+	if len(d.pending) == 0 {
+		return
+	}
+	db := d.db
+	db.mustNotBeFrozen("Flush")
+
+	// Sort the buffered objects by bin, the "other" bin being the last one.
+	// The sort is stable so that objects end up in their bins in the same
+	// order as if they had been attached one by one.
+	idx := make([]int, len(d.pending))
+	start := make([]int, len(db.bins)+2)
+	for i, p := range d.pending {
+		b := db.binIndex(p.x, p.y)
+		if b < 0 {
+			b = len(db.bins)
+		}
+		idx[i] = b
+		start[b+1]++
+	}
+	for b := 1; b < len(start); b++ {
+		start[b] += start[b-1]
+	}
+	sorted := make([]*Proxy[T], len(d.pending))
+	for i, p := range d.pending {
+		sorted[start[idx[i]]] = p
+		start[idx[i]]++
+	}
+
+	// start[b] is now the end of bin b objects in sorted.
+	i := 0
+	for b := 0; b <= len(db.bins); b++ {
+		bin := &db.other
+		if b < len(db.bins) {
+			bin = &db.bins[b]
+		}
+		for ; i < start[b]; i++ {
+			db.link(sorted[i], bin, db.insertAtTail)
+		}
+	}
+	d.pending = d.pending[:0]
+	db.checkOtherThreshold()
+}
+
+// DB flushes the buffered objects and returns the underlying database.
+func (d *DeferredDB[T]) DB() *DB[T] {
+	d.Flush()
+	return d.db
+}
+
+// Len returns the number of objects in the database, buffered objects
+// included.
+func (d *DeferredDB[T]) Len() int {
+	return d.db.Len() + len(d.pending)
+}
+
+// Update flushes the buffered objects, then updates the location of a proxy
+// object in the database (see DB.Update).
+func (d *DeferredDB[T]) Update(obj *Proxy[T], x, y float64) {
+	d.Flush()
+	d.db.Update(obj, x, y)
+}
+
+// Detach flushes the buffered objects, then detaches the given proxy object
+// from the database.
+func (d *DeferredDB[T]) Detach(obj *Proxy[T]) {
+	d.Flush()
+	d.db.Detach(obj)
+}
+
+// ForEachObject flushes the buffered objects, then applies a user-supplied
+// function to all objects in the database (see DB.ForEachObject).
+func (d *DeferredDB[T]) ForEachObject(f Func[T]) {
+	d.Flush()
+	d.db.ForEachObject(f)
+}
+
+// ForEachWithinRadius flushes the buffered objects, then applies a
+// user-supplied function to all objects in a certain locality (see
+// DB.ForEachWithinRadius).
+func (d *DeferredDB[T]) ForEachWithinRadius(x, y, radius float64, f Func[T]) {
+	d.Flush()
+	d.db.ForEachWithinRadius(x, y, radius, f)
+}
+
+// FindNearestInRadius flushes the buffered objects, then searches the database
+// to find the object nearest to a given location yet within a given radius
+// (see DB.FindNearestInRadius).
+func (d *DeferredDB[T]) FindNearestInRadius(x, y, radius float64, ignored T) (T, bool) {
+	d.Flush()
+	return d.db.FindNearestInRadius(x, y, radius, ignored)
+}
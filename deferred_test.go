@@ -0,0 +1,59 @@
+package lq
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeferredDB(t *testing.T) {
+	pts := [][2]float64{{1, 1}, {1.5, 1.5}, {5, 5}, {9, 9}, {1.2, 1.1}, {-1, -1}, {12, 3}, {5.1, 5.1}}
+
+	eager := NewDB[int](0, 0, 10, 10, 5, 5)
+	d := NewDeferred(NewDB[int](0, 0, 10, 10, 5, 5))
+	var proxies []*Proxy[int]
+	for i, pt := range pts {
+		eager.Attach(i, pt[0], pt[1])
+		proxies = append(proxies, d.Attach(i, pt[0], pt[1]))
+	}
+
+	// Nothing is attached before the first query.
+	if d.db.Len() != 0 || d.Len() != len(pts) {
+		t.Fatalf("before flush: db.Len(), Len() = %d, %d, want 0, %d", d.db.Len(), d.Len(), len(pts))
+	}
+
+	ids := make(idset)
+	d.ForEachWithinRadius(1, 1, 1, ids.storeID)
+	ids.assertContains(t, 0)
+	ids.assertContains(t, 1)
+	ids.assertContains(t, 4)
+	if d.db.Len() != len(pts) || d.db.OtherCount() != 2 || len(d.pending) != 0 {
+		t.Errorf("after flush: Len(), OtherCount(), pending = %d, %d, %d, want %d, 2, 0",
+			d.db.Len(), d.db.OtherCount(), len(d.pending), len(pts))
+	}
+
+	// Bins contents and order are the same as with eager attaches.
+	order := func(db *DB[int]) string {
+		var ids []int
+		db.ForEachObject(func(id int, _ float64) { ids = append(ids, id) })
+		return fmt.Sprint(ids)
+	}
+	if got, want := order(d.DB()), order(eager); got != want {
+		t.Errorf("iteration order = %s, want %s", got, want)
+	}
+
+	// Objects attached later are flushed by the next query.
+	d.Attach(10, 9.5, 9.5)
+	if got, found := d.FindNearestInRadius(10, 10, 1, -1); !found || got != 10 {
+		t.Errorf("FindNearestInRadius() = %v, %t, want 10, true", got, found)
+	}
+
+	d.Update(proxies[2], 8, 8)
+	d.Detach(proxies[3])
+	ids = make(idset)
+	d.ForEachWithinRadius(8.5, 8.5, 1, ids.storeID)
+	ids.assertContains(t, 2)
+	ids.assertNotContains(t, 3)
+	if d.Len() != len(pts) {
+		t.Errorf("Len() = %d, want %d", d.Len(), len(pts))
+	}
+}
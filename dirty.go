@@ -0,0 +1,72 @@
+package lq
+
+// DirtyTracker reports whether objects have been attached, moved or detached
+// in a region of a database, see DB.NewDirtyTracker.
+type DirtyTracker[T comparable] struct {
+	db                     *DB[T]
+	xmin, ymin, xmax, ymax float64
+
+	// Value of the database clock at the last check.
+	checked uint64
+}
+
+// NewDirtyTracker returns a tracker of the mutations in the axis-aligned
+// rectangle going from (xmin,ymin) to (xmax,ymax).
+//
+// Mutations are tracked per bin: the tracker reports the mutations of the bins
+// overlapping the rectangle, even if they happened out of it, and, if the
+// rectangle extends outside of the super-brick, those of the "other" bin. The
+// first call to NewDirtyTracker enables dirty tracking in the database.
+func (db *DB[T]) NewDirtyTracker(xmin, ymin, xmax, ymax float64) *DirtyTracker[T] {
+	db.mustNotBeFrozen("NewDirtyTracker")
+	if db.dirty == nil {
+		db.dirty = make([]uint64, len(db.bins))
+	}
+	return &DirtyTracker[T]{
+		db:      db,
+		xmin:    xmin,
+		ymin:    ymin,
+		xmax:    xmax,
+		ymax:    ymax,
+		checked: db.clock,
+	}
+}
+
+// Check reports whether an object has been attached, moved or detached in the
+// tracked region since the previous call to Check, or since the tracker
+// creation.
+//
+// The cost of Check is proportional to the number of bins overlapping the
+// tracked region, which is typically much lower than querying it again.
+func (t *DirtyTracker[T]) Check() bool {
+	db := t.db
+	checked := t.checked
+	t.checked = db.clock
+	if db.clock == checked {
+		// Nothing changed anywhere.
+		return false
+	}
+
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(t.xmin, t.ymin, t.xmax, t.ymax)
+	if outside && db.otherDirty > checked {
+		return true
+	}
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			if db.dirty[db.coordsToIndex(i, j)] > checked {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markDirty records a mutation of the bin of index i, or of the "other" bin if
+// i is negative, at the current clock. Dirty tracking must be enabled.
+func (db *DB[T]) markDirty(i int) {
+	if i < 0 {
+		db.otherDirty = db.clock
+		return
+	}
+	db.dirty[i] = db.clock
+}
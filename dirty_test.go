@@ -0,0 +1,56 @@
+package lq
+
+import "testing"
+
+func TestDirtyTracker(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	p1 := db.Attach(1, 1, 1)
+	p2 := db.Attach(2, 9, 9)
+
+	// The tracked region overlaps bins (0,0) to (1,1).
+	tr := db.NewDirtyTracker(0.5, 0.5, 3.5, 3.5)
+	if tr.Check() {
+		t.Errorf("Check() = true before any mutation")
+	}
+
+	steps := []struct {
+		name   string
+		mutate func()
+		dirty  bool
+	}{
+		{"move outside", func() { db.Update(p2, 8, 9) }, false},
+		{"attach outside", func() { db.Attach(3, 7, 7) }, false},
+		{"move within bin", func() { db.Update(p1, 1.5, 1) }, true},
+		{"no mutation", func() {}, false},
+		{"attach inside", func() { db.Attach(4, 3, 3) }, true},
+		{"move in", func() { db.Update(p2, 2, 2) }, true},
+		{"move out", func() { db.Update(p2, 9, 9) }, true},
+		{"detach inside", func() { db.Detach(p1) }, true},
+		{"attach in other bin", func() { db.Attach(5, -1, 5) }, false},
+		{"attach in region bins, out of region", func() { db.Attach(6, 3.9, 0.1) }, true},
+		{"recenter", func() { db.Recenter(6, 6) }, true},
+	}
+	for _, s := range steps {
+		s.mutate()
+		if got := tr.Check(); got != s.dirty {
+			t.Errorf("%s: Check() = %t, want %t", s.name, got, s.dirty)
+		}
+	}
+
+	// Trackers are independent, and a region extending outside of the
+	// super-brick tracks the "other" bin.
+	tr1 := db.NewDirtyTracker(-2, 2, 2, 4)
+	tr2 := db.NewDirtyTracker(7, 7, 9, 9)
+	db.Attach(7, -1, 3)
+	if !tr1.Check() {
+		t.Errorf("tr1.Check() = false after an attach in the other bin")
+	}
+	if tr2.Check() {
+		t.Errorf("tr2.Check() = true after an attach in the other bin")
+	}
+
+	db.Regrid(10, 10)
+	if !tr1.Check() || !tr2.Check() {
+		t.Errorf("Check() = false after Regrid")
+	}
+}
@@ -0,0 +1,49 @@
+package lq_test
+
+import (
+	"fmt"
+
+	lq "github.com/arl/golq"
+)
+
+type boid struct {
+	name  string
+	proxy *lq.Proxy[*boid]
+}
+
+func Example() {
+	// Create a database covering a 100x100 area, subdivided into 10x10 bins.
+	db := lq.NewDB[*boid](0, 0, 100, 100, 10, 10)
+
+	// Attach objects at their locations, keeping their proxies around.
+	alice := &boid{name: "alice"}
+	alice.proxy = db.Attach(alice, 10, 10)
+	bob := &boid{name: "bob"}
+	bob.proxy = db.Attach(bob, 50, 50)
+	carol := &boid{name: "carol"}
+	carol.proxy = db.Attach(carol, 90, 90)
+
+	// Bob moves toward Alice.
+	db.Update(bob.proxy, 15, 12)
+
+	// Count the objects near Alice.
+	count := 0
+	db.ForEachWithinRadius(10, 10, 20, func(b *boid, sqDist float64) {
+		count++
+	})
+	fmt.Println("objects near alice:", count)
+
+	// Find Alice's nearest neighbor.
+	if nearest, ok := db.FindNearestInRadius(10, 10, 20, alice); ok {
+		fmt.Println("alice's nearest neighbor:", nearest.name)
+	}
+
+	// Carol leaves.
+	db.Detach(carol.proxy)
+	fmt.Println("objects left:", db.Len())
+
+	// Output:
+	// objects near alice: 2
+	// alice's nearest neighbor: bob
+	// objects left: 2
+}
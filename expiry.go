@@ -0,0 +1,72 @@
+package lq
+
+import "container/heap"
+
+// AttachExpiring attaches a new object to the database, which expires at the
+// given tick, and returns a proxy object.
+//
+// The object is detached by the first call to Step with a tick greater than or
+// equal to expireAt. This is meant for transient objects, such as projectiles
+// or timed effects, whose lifetime is then managed by the database. Detaching
+// the object, or migrating it to another database, cancels its expiry: once
+// attached back, it doesn't expire anymore.
+func (db *DB[T]) AttachExpiring(t T, x, y float64, expireAt int64) *Proxy[T] {
+	db.mustNotBeFrozen("AttachExpiring")
+	obj := &Proxy[T]{object: t, layer: AllLayers, seq: db.nextSeq(), expireAt: expireAt}
+	db.Update(obj, x, y)
+	heap.Push(&db.expiring, obj)
+	return obj
+}
+
+// Step detaches all objects attached with AttachExpiring whose expiry tick is
+// less than or equal to now, and returns the number of detached objects.
+//
+// The cost of Step is proportional to the logarithm of the number of expiring
+// objects, for each object that expired since the previous call.
+func (db *DB[T]) Step(now int64) int {
+	db.mustNotBeFrozen("Step")
+
+	culled := 0
+	for len(db.expiring) > 0 && db.expiring[0].expireAt <= now {
+		// Only attached objects are in the heap, detaching them removes them.
+		db.detach(db.expiring[0])
+		culled++
+	}
+	db.checkOtherThreshold()
+	return culled
+}
+
+// cancelExpiry removes an expiring proxy from the heap of expiring objects.
+func (db *DB[T]) cancelExpiry(obj *Proxy[T]) {
+	heap.Remove(&db.expiring, obj.expiry-1)
+}
+
+// expiryHeap is a min-heap of the proxies of expiring objects, ordered by
+// expiry tick. It implements heap.Interface, and keeps track of the index of
+// each proxy in the heap so that it can be removed when it's detached.
+type expiryHeap[T any] []*Proxy[T]
+
+func (h expiryHeap[T]) Len() int           { return len(h) }
+func (h expiryHeap[T]) Less(i, j int) bool { return h[i].expireAt < h[j].expireAt }
+
+func (h expiryHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].expiry = i + 1
+	h[j].expiry = j + 1
+}
+
+func (h *expiryHeap[T]) Push(x any) {
+	p := x.(*Proxy[T])
+	p.expiry = len(*h) + 1
+	*h = append(*h, p)
+}
+
+func (h *expiryHeap[T]) Pop() any {
+	old := *h
+	n := len(old)
+	p := old[n-1]
+	old[n-1] = nil // don't retain the proxy
+	p.expiry = 0
+	*h = old[:n-1]
+	return p
+}
@@ -0,0 +1,99 @@
+package lq
+
+import "testing"
+
+func TestStep(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5) // never expires
+	db.AttachExpiring(2, 1, 1, 10)
+	p3 := db.AttachExpiring(3, 2, 2, 5)
+	db.AttachExpiring(4, -1, 3, 10) // in the other bin
+	p5 := db.AttachExpiring(5, 8, 8, 3)
+	p6 := db.AttachExpiring(6, 7, 7, 20)
+	db.AttachExpiring(7, 3, 3, 20)
+
+	// Moving an object keeps its expiry.
+	db.Update(p3, 9, 1)
+	// Detaching an object cancels its expiry, even if it's attached back.
+	db.Detach(p5)
+	db.Detach(p6)
+	db.Update(p6, 6, 6)
+
+	var tests = []struct {
+		now    int64
+		culled int
+		want   []int
+	}{
+		{0, 0, []int{1, 2, 3, 4, 6, 7}},
+		{5, 1, []int{1, 2, 4, 6, 7}},
+		{9, 0, []int{1, 2, 4, 6, 7}},
+		{15, 2, []int{1, 6, 7}},
+		{20, 1, []int{1, 6}},
+		{100, 0, []int{1, 6}},
+	}
+	for _, tt := range tests {
+		if got := db.Step(tt.now); got != tt.culled {
+			t.Errorf("Step(%d) = %d, want %d", tt.now, got, tt.culled)
+		}
+		if db.Len() != len(tt.want) {
+			t.Errorf("after Step(%d), Len() = %d, want %d", tt.now, db.Len(), len(tt.want))
+		}
+		ids := make(idset)
+		db.ForEachObject(ids.storeID)
+		for _, id := range tt.want {
+			ids.assertContains(t, id)
+		}
+	}
+
+	// Detached objects don't linger in the heap.
+	if len(db.expiring) != 0 {
+		t.Errorf("%d objects left in the expiry heap, want 0", len(db.expiring))
+	}
+}
+
+func TestStepMigrate(t *testing.T) {
+	a := NewDB[int](0, 0, 10, 10, 5, 5)
+	b := NewDB[int](0, 0, 10, 10, 5, 5)
+	p := a.AttachExpiring(1, 5, 5, 10)
+	a.AttachExpiring(2, 6, 6, 10)
+	a.Migrate(p, b, 3, 3)
+
+	if got := a.Step(20); got != 1 {
+		t.Errorf("a.Step(20) = %d, want 1", got)
+	}
+	if a.Len() != 0 || b.Len() != 1 {
+		t.Errorf("a.Len() = %d, b.Len() = %d, want 0, 1", a.Len(), b.Len())
+	}
+	ids := make(idset)
+	b.ForEachWithinRadius(3, 3, 1, ids.storeID)
+	ids.assertContains(t, 1)
+
+	// The migrated object doesn't expire in b either.
+	if got := b.Step(20); got != 0 {
+		t.Errorf("b.Step(20) = %d, want 0", got)
+	}
+}
+
+func TestStepDetachAll(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	for i := 0; i < 10; i++ {
+		db.AttachExpiring(i, float64(i), float64(i), int64(i))
+	}
+	db.DetachBin(0, 0)
+	db.Walk(func(p *Proxy[int]) WalkAction {
+		if p.Object() == 5 {
+			return Detach
+		}
+		return Keep
+	})
+	if got := db.Step(5); got != 3 {
+		t.Errorf("Step(5) = %d, want 3", got)
+	}
+	db.DetachAll()
+	if len(db.expiring) != 0 {
+		t.Errorf("%d objects left in the expiry heap after DetachAll, want 0", len(db.expiring))
+	}
+	if got := db.Step(100); got != 0 {
+		t.Errorf("Step(100) = %d, want 0", got)
+	}
+}
@@ -0,0 +1,156 @@
+package lq
+
+// frozenEntry is the compact copy of a proxy stored in a frozen database.
+type frozenEntry[T any] struct {
+	object T
+	x, y   float64
+	seq    uint64
+}
+
+// Freeze compacts the database into a read-optimized form.
+//
+// The content of each bin is copied into a contiguous slice, which is more
+// cache friendly than walking the bins linked lists. The slice is allocated
+// once, sized for all the objects, those of the "other" bin included, so
+// freezing never reallocates, however many objects are outside of the
+// super-brick. This is useful for read-heavy workloads, such as static level
+// geometry that gets loaded once and then queried constantly. A frozen
+// database can't be modified: Attach, Update, Detach and all the other methods
+// mutating the database panic until Unfreeze is called. Freezing a frozen
+// database has no effect.
+func (db *DB[T]) Freeze() {
+	if db.frozenIdx != nil {
+		return
+	}
+
+	n := 0
+	db.ForEachObject(func(T, float64) { n++ })
+
+	// Entries of bin i are frozen[frozenIdx[i]:frozenIdx[i+1]], the "other"
+	// bin being the last one.
+	db.frozen = make([]frozenEntry[T], 0, n)
+	db.frozenIdx = make([]int, 0, len(db.bins)+2)
+	freeze := func(cp *Proxy[T]) {
+		db.frozenIdx = append(db.frozenIdx, len(db.frozen))
+		for ; cp != nil; cp = cp.next {
+			db.frozen = append(db.frozen, frozenEntry[T]{object: cp.object, x: cp.x, y: cp.y, seq: cp.seq})
+		}
+	}
+	for i := range db.bins {
+		freeze(db.bins[i])
+	}
+	freeze(db.other)
+	db.frozenIdx = append(db.frozenIdx, len(db.frozen))
+}
+
+// Unfreeze makes a frozen database modifiable again, and releases the memory
+// used by its read-optimized form. Unfreezing a database that is not frozen has
+// no effect.
+func (db *DB[T]) Unfreeze() {
+	db.frozen = nil
+	db.frozenIdx = nil
+}
+
+// Frozen reports whether the database is frozen.
+func (db *DB[T]) Frozen() bool {
+	return db.frozenIdx != nil
+}
+
+// mustNotBeFrozen panics if the database is frozen.
+func (db *DB[T]) mustNotBeFrozen(method string) {
+	if db.frozenIdx != nil {
+		panic("lq: " + method + " called on a frozen DB")
+	}
+}
+
+// forEachWithinRadiusFrozen is the version of ForEachWithinRadius used when the
+// database is frozen.
+func (db *DB[T]) forEachWithinRadiusFrozen(x, y, radius float64, f Func[T]) {
+	sqRadius := radius * radius
+	traverse := func(bin int) {
+		entries := db.frozen[db.frozenIdx[bin]:db.frozenIdx[bin+1]]
+		for i := range entries {
+			e := &entries[i]
+			sqDist := (x-e.x)*(x-e.x) + (y-e.y)*(y-e.y)
+			if sqDist < sqRadius {
+				f(e.object, sqDist)
+			}
+		}
+	}
+
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	if outside {
+		traverse(len(db.bins))
+	}
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			traverse(db.coordsToIndex(i, j))
+		}
+	}
+}
+
+// ReadSnapshot returns a frozen copy of the database.
+//
+// The snapshot can be queried while the database keeps being modified, for
+// example from a renderer running in another goroutine than the simulation.
+// Since it's frozen, the snapshot can't be modified itself and thus doesn't
+// require any locking. Taking a snapshot copies every proxy so its cost is
+// proportional to the number of objects: a snapshot is typically taken once
+// per frame. ReadSnapshot itself reads the database so it must not be called
+// concurrently with methods modifying it.
+func (db *DB[T]) ReadSnapshot() *DB[T] {
+	snap := db.clone()
+	snap.Freeze()
+	return snap
+}
+
+// clone returns a deep copy of the database, with the same settings and
+// objects but separate proxies. Query statistics and callbacks are not copied.
+func (db *DB[T]) clone() *DB[T] {
+	cpy := *db
+	cpy.bins = make([]*Proxy[T], len(db.bins))
+	cpy.other = nil
+	cpy.stats = nil
+	cpy.frozen, cpy.frozenIdx = nil, nil
+	cpy.otherCb = nil
+	cpy.otherAlerted = false
+	cpy.gens = append([]uint64(nil), db.gens...)
+	cpy.dirty = nil
+	cpy.liveCounts = append([]int(nil), db.liveCounts...)
+	cpy.occupied = nil
+	cpy.expiring = make(expiryHeap[T], len(db.expiring))
+	if db.radii != nil {
+		cpy.radii = make(map[float64]int, len(db.radii))
+		for r, n := range db.radii {
+			cpy.radii[r] = n
+		}
+	}
+
+	// Copy the bins contents, preserving the order of their lists.
+	copyBin := func(dst **Proxy[T], cp *Proxy[T]) {
+		var last *Proxy[T]
+		for ; cp != nil; cp = cp.next {
+			p := *cp
+			p.prev, p.next, p.bin = last, nil, dst
+			if p.expiry != 0 {
+				// Same place in the heap as the original proxy.
+				cpy.expiring[p.expiry-1] = &p
+			}
+			if last == nil {
+				*dst = &p
+			} else {
+				last.next = &p
+			}
+			last = &p
+		}
+	}
+	for i := range db.bins {
+		copyBin(&cpy.bins[i], db.bins[i])
+	}
+	copyBin(&cpy.other, db.other)
+	if cpy.occupiedOK {
+		// The non-empty bins are those of the copy.
+		cpy.findOccupied()
+	}
+	return &cpy
+}
@@ -0,0 +1,212 @@
+package lq
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFrozenObjectLocality(t *testing.T) {
+	var tests = []struct {
+		cx, cy float64 // search circle center
+		cr     float64 // search circle radius
+	}{
+		{1, 1, 0.1},
+		{1, 1, 1.1},
+		{1, 1, 2.1},
+		{5, 5, 3},
+		{-1, -1, 3},
+		{11, 11, 2},
+		{-20, -20, 1},
+		{5, 5, 100},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("frozen locality test %d", i), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 1, 1)
+			db.Attach(2, 1, 2)
+			db.Attach(3, 1, 3)
+			db.Attach(4, 5, 5)
+			db.Attach(5, 9.9, 9.9)
+			db.Attach(6, -1, -1)
+			db.Attach(7, 12, 12)
+
+			want := make(idset)
+			db.ForEachWithinRadius(tt.cx, tt.cy, tt.cr, want.storeID)
+
+			db.Freeze()
+			if !db.Frozen() {
+				t.Fatalf("Frozen() = false after Freeze()")
+			}
+
+			got := make(idset)
+			db.ForEachWithinRadius(tt.cx, tt.cy, tt.cr, got.storeID)
+			for id := 1; id <= 7; id++ {
+				_, ok := want[id]
+				got.assertIsContained(t, id, ok)
+			}
+
+			db.Unfreeze()
+			if db.Frozen() {
+				t.Fatalf("Frozen() = true after Unfreeze()")
+			}
+		})
+	}
+}
+
+func TestFreezeAllocs(t *testing.T) {
+	for _, n := range []int{10, 10000} {
+		db := NewDB[int](0, 0, 10, 10, 5, 5)
+		for i := 0; i < n; i++ {
+			db.Attach(i, -1-float64(i), 5) // in the other bin
+		}
+		db.Attach(n, 5, 5)
+
+		// The entries and the bin offsets are allocated once each, whatever
+		// the number of objects in the other bin.
+		allocs := testing.AllocsPerRun(10, func() {
+			db.Freeze()
+			db.Unfreeze()
+		})
+		if allocs > 2 {
+			t.Errorf("%d objects in the other bin: Freeze allocates %v times, want at most 2", n, allocs)
+		}
+	}
+}
+
+func TestFrozenMutationPanics(t *testing.T) {
+	var tests = []struct {
+		name   string
+		mutate func(db *DB[int], p *Proxy[int])
+	}{
+		{"Attach", func(db *DB[int], p *Proxy[int]) { db.Attach(2, 1, 1) }},
+		{"AttachLayer", func(db *DB[int], p *Proxy[int]) { db.AttachLayer(2, 1, 1, 1) }},
+		{"Update", func(db *DB[int], p *Proxy[int]) { db.Update(p, 2, 2) }},
+		{"Detach", func(db *DB[int], p *Proxy[int]) { db.Detach(p) }},
+		{"DetachReport", func(db *DB[int], p *Proxy[int]) { db.DetachReport(p) }},
+		{"DetachAll", func(db *DB[int], p *Proxy[int]) { db.DetachAll() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			p := db.Attach(1, 5, 5)
+			db.Freeze()
+
+			func() {
+				defer func() {
+					want := "lq: " + tt.name + " called on a frozen DB"
+					if r := recover(); r != want {
+						t.Errorf("recovered %v, want panic %q", r, want)
+					}
+				}()
+				tt.mutate(db, p)
+			}()
+
+			// Once unfrozen, the database can be mutated again.
+			db.Unfreeze()
+			tt.mutate(db, p)
+		})
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	proxies := make([]*Proxy[int], 0, 100)
+	for i := 0; i < 100; i++ {
+		proxies = append(proxies, db.Attach(i, float64(i%10), float64(i/10)))
+	}
+	db.Attach(100, -1, -1)
+
+	snap := db.ReadSnapshot()
+	if !snap.Frozen() {
+		t.Fatalf("snapshot isn't frozen")
+	}
+	if snap.Len() != db.Len() || snap.OtherCount() != db.OtherCount() {
+		t.Fatalf("snapshot Len(), OtherCount() = %d, %d, want %d, %d",
+			snap.Len(), snap.OtherCount(), db.Len(), db.OtherCount())
+	}
+
+	want := make(idset)
+	db.ForEachWithinRadius(3, 3, 2.5, want.storeID)
+	want.storeID(100, 0)
+
+	// Mutate the live database while the snapshot is being queried.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 0; n < 100; n++ {
+			for i, p := range proxies {
+				db.Update(p, float64((i+n)%10), float64(i/10))
+			}
+		}
+		db.DetachAll()
+	}()
+
+	for n := 0; n < 100; n++ {
+		got := make(idset)
+		snap.ForEachWithinRadius(3, 3, 2.5, got.storeID)
+		snap.ForEachWithinRadius(-1, -1, 0.5, got.storeID)
+		if len(got) != len(want) {
+			t.Fatalf("snapshot query returned %d objects, want %d", len(got), len(want))
+		}
+		for id := range want {
+			got.assertContains(t, id)
+		}
+	}
+	<-done
+
+	// The snapshot is left untouched by the modifications of the database.
+	ids := make(idset)
+	snap.ForEachObject(ids.storeID)
+	if len(ids) != 101 {
+		t.Errorf("snapshot contains %d objects, want 101", len(ids))
+	}
+}
+
+func TestReadSnapshotBruteForce(t *testing.T) {
+	db := New[int](WithBounds(0, 0, 10, 10), WithDivisions(5, 5), WithBruteForceThreshold(8))
+	p := db.Attach(1, 5, 5)
+
+	snap := db.ReadSnapshot()
+	snap.Unfreeze()
+	// Moving the object empties its bin in db, which mustn't affect the
+	// snapshot.
+	db.Update(p, 9, 9)
+
+	ids := make(idset)
+	snap.ForEachWithinRadius(5, 5, 1, ids.storeID)
+	ids.assertContains(t, 1)
+	ids = make(idset)
+	db.ForEachWithinRadius(5, 5, 1, ids.storeID)
+	ids.assertEmpty(t)
+}
+
+func TestReadSnapshotExpiring(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	for i := 0; i < 10; i++ {
+		db.AttachExpiring(i, float64(i), 5, int64(i))
+	}
+	db.Attach(10, 5, 5)
+
+	snap := db.ReadSnapshot()
+	snap.Unfreeze()
+
+	// Expiries of the database and of the snapshot are independent.
+	if got := db.Step(4); got != 5 {
+		t.Errorf("db.Step(4) = %d, want 5", got)
+	}
+	if got := snap.Step(1); got != 2 {
+		t.Errorf("snap.Step(1) = %d, want 2", got)
+	}
+	if db.Len() != 6 || snap.Len() != 9 {
+		t.Errorf("db.Len() = %d, snap.Len() = %d, want 6, 9", db.Len(), snap.Len())
+	}
+	if got := snap.Step(100); got != 8 {
+		t.Errorf("snap.Step(100) = %d, want 8", got)
+	}
+	if got := db.Step(100); got != 5 {
+		t.Errorf("db.Step(100) = %d, want 5", got)
+	}
+	if db.Len() != 1 || snap.Len() != 1 {
+		t.Errorf("db.Len() = %d, snap.Len() = %d, want 1, 1", db.Len(), snap.Len())
+	}
+}
@@ -0,0 +1,57 @@
+package lq
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func FuzzForEachWithinRadius(f *testing.F) {
+	f.Add(0.0, 0.0, 10.0, 10.0, uint8(5), uint8(5), int64(1), 5.0, 5.0, 2.0, uint8(0))
+	f.Add(-3.5, 2.0, 100.0, 10.0, uint8(10), uint8(10), int64(2), 0.0, 10.0, 5.0, uint8(1))
+	f.Add(0.0, 0.0, 1e-9, 1e-9, uint8(3), uint8(1), int64(3), 0.0, 0.0, 1e-10, uint8(2))
+	f.Add(0.0, 0.0, 10.0, 10.0, uint8(1), uint8(1), int64(4), -0.5, 5.0, 0.6, uint8(0))
+	f.Add(0.0, 0.0, 10.0, 10.0, uint8(7), uint8(3), int64(5), 10.0, 10.0, 0.0, uint8(2))
+
+	f.Fuzz(func(t *testing.T, xorg, yorg, szx, szy float64, xdiv, ydiv uint8, seed int64, x, y, radius float64, oob uint8) {
+		if !(szx > 0) || !(szy > 0) || math.IsInf(szx, 0) || math.IsInf(szy, 0) ||
+			math.IsNaN(xorg) || math.IsNaN(yorg) || math.IsInf(xorg, 0) || math.IsInf(yorg, 0) ||
+			xdiv == 0 || ydiv == 0 || math.IsNaN(radius) || radius < 0 {
+			t.Skip()
+		}
+		policy := OutOfBoundsPolicy(oob % 2) // CatchAll or ClampToEdge
+		db := New[int](WithBounds(xorg, yorg, szx, szy), WithDivisions(int(xdiv), int(ydiv)), WithOutOfBoundsPolicy(policy))
+
+		// Objects in and around the super-brick, some of them on bin borders.
+		rng := rand.New(rand.NewSource(seed))
+		coord := func(org, sz float64, div int) float64 {
+			switch rng.Intn(3) {
+			case 0:
+				return org + float64(rng.Intn(div+1))*sz/float64(div)
+			case 1:
+				return org - sz/2 + rng.Float64()*sz*2
+			}
+			return org + rng.Float64()*sz
+		}
+		for i := 0; i < 50; i++ {
+			db.Attach(i, coord(xorg, szx, int(xdiv)), coord(yorg, szy, int(ydiv)))
+		}
+
+		want := make(map[int]float64)
+		db.ForEachWithinRadiusBruteForce(x, y, radius, func(id int, sqDist float64) {
+			want[id] = sqDist
+		})
+		got := make(map[int]int)
+		db.ForEachWithinRadius(x, y, radius, func(id int, sqDist float64) {
+			got[id]++
+			if _, ok := want[id]; !ok {
+				t.Errorf("object %d at squared distance %v found", id, sqDist)
+			}
+		})
+		for id := range want {
+			if got[id] != 1 {
+				t.Errorf("object %d at squared distance %v found %d times, want once", id, want[id], got[id])
+			}
+		}
+	})
+}
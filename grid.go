@@ -0,0 +1,336 @@
+package lq
+
+import (
+	"math"
+	"sort"
+)
+
+// Recenter moves the super-brick so that it's centered on (cx,cy).
+//
+// The super-brick keeps its size and subdivisions, and all objects are
+// re-binned according to the new super-brick position: objects which fall
+// outside of it go to the "other" bin. This keeps the spatial subdivision
+// over the area of interest, when it follows a moving focus point (e.g. the
+// player in an open world game). The cost of Recenter is proportional to the
+// number of objects in the database.
+func (db *DB[T]) Recenter(cx, cy float64) {
+	db.mustNotBeFrozen("Recenter")
+
+	db.rebin(func() {
+		db.xorg = cx - db.szx/2
+		db.yorg = cy - db.szy/2
+	})
+}
+
+// rebin detaches all objects, calls change, which modifies the super-brick
+// properties, then attaches back all objects to the bins corresponding to
+// their location.
+func (db *DB[T]) rebin(change func()) {
+	objs := db.appendProxies(make([]*Proxy[T], 0, db.n))
+	for _, obj := range objs {
+		db.unlink(obj)
+	}
+	change()
+
+	// Bins don't cover the same areas anymore, they've all changed.
+	if len(db.gens) != len(db.bins) {
+		db.gens = make([]uint64, len(db.bins))
+	}
+	db.clock++
+	for i := range db.gens {
+		db.gens[i] = db.clock
+	}
+	if db.dirty != nil {
+		if len(db.dirty) != len(db.bins) {
+			db.dirty = make([]uint64, len(db.bins))
+		}
+		copy(db.dirty, db.gens)
+		db.otherDirty = db.clock
+	}
+	if db.liveCounts != nil && len(db.liveCounts) != len(db.bins) {
+		// All objects are unlinked, the bins are all empty.
+		db.liveCounts = make([]int, len(db.bins))
+	}
+	for _, obj := range objs {
+		db.link(obj, db.binForLocation(obj.x, obj.y), db.insertAtTail)
+	}
+	db.checkOtherThreshold()
+}
+
+// Prewarm touches the memory used by the bin array, and by the read-optimized
+// form of a frozen database, so that it's backed by physical memory.
+//
+// This is an optional optimization: on some platforms memory is only mapped
+// the first time it's written to, so calling Prewarm at startup avoids page
+// faults during the first queries, which matters for latency-sensitive
+// applications. Prewarm leaves the contents of the database unchanged, but
+// since it has to write to that memory it's a modification as far as
+// concurrency is concerned: it must not run concurrently with any other
+// method, queries on a frozen database included. With a SyncDB, call it from
+// Write.
+func (db *DB[T]) Prewarm() {
+	// Each element is written back, reading it alone wouldn't be enough: a
+	// read may be served by a shared zero page, which doesn't map the memory.
+	for i, head := range db.bins {
+		db.bins[i] = head
+	}
+	for i, e := range db.frozen {
+		db.frozen[i] = e
+	}
+	for i, off := range db.frozenIdx {
+		db.frozenIdx[i] = off
+	}
+}
+
+// Regrid changes the number of subdivisions of the super-brick along each
+// axis.
+//
+// The super-brick keeps its position and size, the bin array is reallocated
+// and all objects are re-binned, so the cost of Regrid is proportional to the
+// number of objects plus the number of bins. SuggestDivisions can be used to
+// choose the new subdivisions. Regrid panics if xdiv or ydiv is less than 1.
+func (db *DB[T]) Regrid(xdiv, ydiv int) {
+	db.mustNotBeFrozen("Regrid")
+	if xdiv < 1 || ydiv < 1 {
+		panic("lq: Regrid divisions must be at least 1")
+	}
+
+	db.rebin(func() {
+		db.xdiv, db.ydiv = xdiv, ydiv
+		db.xscale = float64(xdiv) / db.szx
+		db.yscale = float64(ydiv) / db.szy
+		db.bins = make([]*Proxy[T], xdiv*ydiv)
+		db.setStrides()
+	})
+}
+
+// SuggestDivisions suggests subdivisions of the super-brick such that its bins
+// contain targetPerBin objects on average.
+//
+// The suggestion is based on the current number of objects, which are assumed
+// to be evenly spread over the super-brick: the number of bins is the number
+// of objects divided by targetPerBin, and the bins are roughly square. Objects
+// outside of the super-brick are not taken into account. SuggestDivisions
+// panics if targetPerBin isn't positive.
+func (db *DB[T]) SuggestDivisions(targetPerBin float64) (xdiv, ydiv int) {
+	if !(targetPerBin > 0) {
+		panic("lq: SuggestDivisions targetPerBin must be positive")
+	}
+
+	nbins := float64(db.n-db.nother) / targetPerBin
+	aspect := db.szx / db.szy
+	xdiv = int(math.Round(math.Sqrt(nbins * aspect)))
+	ydiv = int(math.Round(math.Sqrt(nbins / aspect)))
+	if xdiv < 1 {
+		xdiv = 1
+	}
+	if ydiv < 1 {
+		ydiv = 1
+	}
+	return xdiv, ydiv
+}
+
+// BinSize returns the size of a bin (or sub-brick) in world units.
+//
+// This helps choosing query radii relative to the bin size, since the cost of
+// a query depends on the number of bins it spans.
+func (db *DB[T]) BinSize() (w, h float64) {
+	return db.szx / float64(db.xdiv), db.szy / float64(db.ydiv)
+}
+
+// MaxBinsForRadius returns the maximum number of bins a radius query can
+// visit, whatever its center, the "other" bin aside.
+//
+// Along each axis, the search circle spans at most ceil(2*radius/binSize+1)
+// bins, which is reached when the circle is badly aligned with the bins, and
+// at most the number of subdivisions of the super-brick. This allows checking
+// at startup that typical query radii don't sweep a large part of the grid.
+func (db *DB[T]) MaxBinsForRadius(radius float64) int {
+	w, h := db.BinSize()
+	nx := int(math.Ceil(2*radius/w + 1))
+	if nx > db.xdiv {
+		nx = db.xdiv
+	}
+	ny := int(math.Ceil(2*radius/h + 1))
+	if ny > db.ydiv {
+		ny = db.ydiv
+	}
+	return nx * ny
+}
+
+// EmptiestBinNear returns the coordinates of the bin containing the fewest
+// objects, in the block of bins centered on the bin containing (x,y), and the
+// number of objects it contains.
+//
+// The block is the same as the one visited by ForEachInBinNeighborhood, made
+// of (2*ring+1)² bins clipped to the super-brick. Among bins containing as few
+// objects, the one whose center is the closest to (x,y) wins. This is useful
+// to spread spawned objects over the less crowded areas. EmptiestBinNear
+// panics if ring is negative.
+func (db *DB[T]) EmptiestBinNear(x, y float64, ring int) (ix, iy int, count int) {
+	if ring < 0 {
+		panic("lq: EmptiestBinNear ring must be non-negative")
+	}
+
+	cx, cy := db.binCoords(x, y)
+	xmin, ymin, xmax, ymax := cx-ring, cy-ring, cx+ring, cy+ring
+	if xmin < 0 {
+		xmin = 0
+	}
+	if ymin < 0 {
+		ymin = 0
+	}
+	if xmax >= db.xdiv {
+		xmax = db.xdiv - 1
+	}
+	if ymax >= db.ydiv {
+		ymax = db.ydiv - 1
+	}
+
+	w, h := db.BinSize()
+	count, minSqDist := -1, 0.0
+	for i := xmin; i <= xmax; i++ {
+		for j := ymin; j <= ymax; j++ {
+			c := binLen(db.bins[db.coordsToIndex(i, j)])
+			if count >= 0 && c > count {
+				continue
+			}
+			dx := db.xorg + (float64(i)+0.5)*w - x
+			dy := db.yorg + (float64(j)+0.5)*h - y
+			sqDist := dx*dx + dy*dy
+			if count < 0 || c < count || sqDist < minSqDist {
+				ix, iy, count, minSqDist = i, j, c, sqDist
+			}
+		}
+	}
+	return ix, iy, count
+}
+
+// KNearestBins returns the coordinates of the k non-empty bins nearest to the
+// bin containing (x,y), or less if there aren't as many.
+//
+// Bins are ordered by the distance between their coordinates and those of the
+// bin containing (x,y), ties being broken by increasing coordinates. (x,y) is
+// clamped to the super-brick, and the "other" bin isn't considered. This is
+// useful for coarse-to-fine processing. The cost of KNearestBins depends on
+// the number of bins to visit before finding k non-empty ones.
+func (db *DB[T]) KNearestBins(x, y float64, k int) [][2]int {
+	if k <= 0 {
+		return nil
+	}
+
+	type bin struct {
+		ix, iy int
+		sqDist int
+	}
+	var bins []bin
+	cx, cy := db.binCoords(x, y)
+	visit := func(i, j int) {
+		if i < 0 || j < 0 || i >= db.xdiv || j >= db.ydiv || db.bins[db.coordsToIndex(i, j)] == nil {
+			return
+		}
+		bins = append(bins, bin{i, j, (i-cx)*(i-cx) + (j-cy)*(j-cy)})
+	}
+
+	// Visit the bins ring by ring, bins of ring r being at least at distance
+	// r. Once k bins have been found, rings need to be visited until they are
+	// farther than the k-th nearest bin found so far.
+	maxRing := db.xdiv
+	if db.ydiv > maxRing {
+		maxRing = db.ydiv
+	}
+	for r := 0; r < maxRing; r++ {
+		if len(bins) >= k {
+			sort.Slice(bins, func(a, b int) bool { return bins[a].sqDist < bins[b].sqDist })
+			if r*r > bins[k-1].sqDist {
+				break
+			}
+		}
+		if r == 0 {
+			visit(cx, cy)
+			continue
+		}
+		for d := -r; d <= r; d++ {
+			visit(cx+d, cy-r)
+			visit(cx+d, cy+r)
+		}
+		for d := -r + 1; d <= r-1; d++ {
+			visit(cx-r, cy+d)
+			visit(cx+r, cy+d)
+		}
+	}
+
+	sort.Slice(bins, func(a, b int) bool {
+		ba, bb := bins[a], bins[b]
+		if ba.sqDist != bb.sqDist {
+			return ba.sqDist < bb.sqDist
+		}
+		if ba.ix != bb.ix {
+			return ba.ix < bb.ix
+		}
+		return ba.iy < bb.iy
+	})
+	if len(bins) > k {
+		bins = bins[:k]
+	}
+	res := make([][2]int, len(bins))
+	for i, b := range bins {
+		res[i] = [2]int{b.ix, b.iy}
+	}
+	return res
+}
+
+// DetachBin detaches all the objects of the bin of coordinates (ix,iy), and
+// returns how many objects have been detached.
+//
+// The cost of DetachBin is proportional to the number of objects in the bin,
+// which is cheaper than querying the objects of an area and then detaching
+// them. DetachBin panics if (ix,iy) are not the coordinates of a bin.
+func (db *DB[T]) DetachBin(ix, iy int) int {
+	db.mustNotBeFrozen("DetachBin")
+	if ix < 0 || iy < 0 || ix >= db.xdiv || iy >= db.ydiv {
+		panic("lq: DetachBin bin coordinates out of range")
+	}
+
+	n := 0
+	pbin := &db.bins[db.coordsToIndex(ix, iy)]
+	for *pbin != nil {
+		db.detach(*pbin)
+		n++
+	}
+	db.checkOtherThreshold()
+	return n
+}
+
+// BinGeneration returns the generation of the bin of coordinates (ix,iy).
+//
+// The generation of a bin increases each time an object is added to or
+// removed from it, moving an object within the bin leaves it unchanged.
+// Generations only ever increase, even when the bins are rearranged by Regrid
+// or Recenter, so query results can be cached along with the generations of
+// the bins they depend on, and invalidated as soon as one of them changes.
+// BinGeneration panics if (ix,iy) are not the coordinates of a bin.
+func (db *DB[T]) BinGeneration(ix, iy int) uint64 {
+	if ix < 0 || iy < 0 || ix >= db.xdiv || iy >= db.ydiv {
+		panic("lq: BinGeneration bin coordinates out of range")
+	}
+	return db.gens[db.coordsToIndex(ix, iy)]
+}
+
+// binChanged records that an object located at (x,y) has been added to or
+// removed from bin.
+func (db *DB[T]) binChanged(bin **Proxy[T], x, y float64) {
+	db.clock++
+	i := -1
+	if bin != &db.other {
+		// Bin indices are recomputed from the object's location, which is
+		// simpler than deriving them from the position of bin in db.bins.
+		i = db.binIndex(x, y)
+	}
+	if i >= 0 {
+		db.gens[i] = db.clock
+	}
+	if db.dirty != nil {
+		db.markDirty(i)
+	}
+}
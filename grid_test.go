@@ -0,0 +1,367 @@
+package lq
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestRecenter(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 1, 1)
+	db.Attach(2, 9, 9)
+	db.Attach(3, 24, 24)
+	db.Attach(4, 19, 21)
+	if db.OtherCount() != 2 {
+		t.Fatalf("OtherCount() = %d, want 2", db.OtherCount())
+	}
+
+	db.Recenter(20, 20)
+
+	// Objects 3 and 4 are now inside the super-brick, objects 1 and 2 are not.
+	if db.Len() != 4 || db.OtherCount() != 2 {
+		t.Fatalf("Len(), OtherCount() = %d, %d, want 4, 2", db.Len(), db.OtherCount())
+	}
+	for id, pos := range map[int][2]float64{3: {24, 24}, 4: {19, 21}} {
+		ix, iy := db.binCoords(pos[0], pos[1])
+		ids := make(idset)
+		db.bins[db.coordsToIndex(ix, iy)].traverseBin(ids.storeID)
+		ids.assertContains(t, id)
+	}
+	others := make(idset)
+	db.other.traverseBin(others.storeID)
+	others.assertContains(t, 1)
+	others.assertContains(t, 2)
+
+	// Queries work as expected around the new center.
+	ids := make(idset)
+	db.ForEachWithinRadius(20, 20, 2, ids.storeID)
+	ids.assertContains(t, 4)
+	ids.assertNotContains(t, 3)
+	ids = make(idset)
+	db.ForEachWithinRadius(2, 2, 2, ids.storeID)
+	ids.assertContains(t, 1)
+	ids.assertNotContains(t, 2)
+}
+
+func TestPrewarm(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Prewarm()
+	db.Attach(1, 1, 1)
+	db.Attach(2, 9, 9)
+	db.Attach(3, -1, -1)
+	db.Prewarm()
+
+	check := func() {
+		t.Helper()
+		ids := make(idset)
+		db.ForEachWithinRadius(0, 0, 2, ids.storeID)
+		ids.assertContains(t, 1)
+		ids.assertContains(t, 3)
+		ids.assertNotContains(t, 2)
+		if db.Len() != 3 {
+			t.Errorf("Len() = %d, want 3", db.Len())
+		}
+	}
+	check()
+	db.Freeze()
+	db.Prewarm()
+	check()
+}
+
+func TestRegrid(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	db := NewDB[int](0, 0, 10, 10, 2, 2)
+	pos := make(map[int][2]float64)
+	for i := 0; i < 300; i++ {
+		pos[i] = [2]float64{12*rng.Float64() - 1, 12*rng.Float64() - 1}
+		db.Attach(i, pos[i][0], pos[i][1])
+	}
+	other := db.OtherCount()
+
+	for _, divs := range [][2]int{{7, 3}, {1, 1}, {20, 20}} {
+		db.Regrid(divs[0], divs[1])
+		if db.Len() != len(pos) || db.OtherCount() != other {
+			t.Fatalf("Len(), OtherCount() = %d, %d, want %d, %d", db.Len(), db.OtherCount(), len(pos), other)
+		}
+		db.ForEachObjectWithBin(func(id int, ix, iy int, inOther bool) {
+			if inOther {
+				return
+			}
+			if wx, wy := db.binCoords(pos[id][0], pos[id][1]); ix != wx || iy != wy {
+				t.Errorf("object %d in bin (%d,%d), want (%d,%d)", id, ix, iy, wx, wy)
+			}
+		})
+		for q := 0; q < 20; q++ {
+			// Queries don't extend past the left and bottom sides of the
+			// super-brick.
+			x, y, r := 2+8*rng.Float64(), 2+8*rng.Float64(), 2*rng.Float64()
+			ids := make(idset)
+			db.ForEachWithinRadius(x, y, r, ids.storeID)
+			for id, p := range pos {
+				ids.assertIsContained(t, id, (p[0]-x)*(p[0]-x)+(p[1]-y)*(p[1]-y) < r*r)
+			}
+		}
+	}
+}
+
+func TestSuggestDivisions(t *testing.T) {
+	var tests = []struct {
+		szx, szy   float64
+		n          int
+		target     float64
+		xdiv, ydiv int
+	}{
+		{10, 10, 10000, 10, 32, 32},
+		{20, 10, 800, 4, 20, 10},
+		{10, 40, 1600, 1, 20, 80},
+		{10, 10, 5, 10, 1, 1},
+		{10, 10, 0, 10, 1, 1},
+	}
+	for _, tt := range tests {
+		db := NewDB[int](0, 0, tt.szx, tt.szy, 1, 1)
+		for i := 0; i < tt.n; i++ {
+			db.Attach(i, tt.szx/2, tt.szy/2)
+		}
+		db.Attach(-1, -1, -1) // outside, not taken into account
+
+		xdiv, ydiv := db.SuggestDivisions(tt.target)
+		if xdiv != tt.xdiv || ydiv != tt.ydiv {
+			t.Errorf("%vx%v, %d objects, target %v: got %dx%d, want %dx%d",
+				tt.szx, tt.szy, tt.n, tt.target, xdiv, ydiv, tt.xdiv, tt.ydiv)
+		}
+	}
+}
+
+func TestBinSize(t *testing.T) {
+	var tests = []struct {
+		szx, szy   float64
+		xdiv, ydiv int
+		w, h       float64
+	}{
+		{10, 10, 5, 5, 2, 2},
+		{100, 50, 4, 10, 25, 5},
+		{1, 3, 1, 1, 1, 3},
+	}
+	for _, tt := range tests {
+		db := NewDB[int](-5, 7, tt.szx, tt.szy, tt.xdiv, tt.ydiv)
+		if w, h := db.BinSize(); w != tt.w || h != tt.h {
+			t.Errorf("%vx%v/%dx%d: BinSize() = (%v, %v), want (%v, %v)", tt.szx, tt.szy, tt.xdiv, tt.ydiv, w, h, tt.w, tt.h)
+		}
+	}
+
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Regrid(2, 4)
+	if w, h := db.BinSize(); w != 5 || h != 2.5 {
+		t.Errorf("after Regrid: BinSize() = (%v, %v), want (5, 2.5)", w, h)
+	}
+}
+
+func TestMaxBinsForRadius(t *testing.T) {
+	var tests = []struct {
+		szx, szy   float64
+		xdiv, ydiv int
+		radius     float64
+		want       int
+	}{
+		{10, 10, 5, 5, 0, 1},
+		{10, 10, 5, 5, 1, 4},
+		{10, 10, 5, 5, 1.5, 9},
+		{10, 10, 5, 5, 3, 16},
+		{10, 10, 5, 5, 10, 25},
+		{100, 50, 4, 10, 5, 6},
+		{100, 50, 4, 10, 30, 40},
+	}
+	for _, tt := range tests {
+		db := NewDB[int](0, 0, tt.szx, tt.szy, tt.xdiv, tt.ydiv)
+		if got := db.MaxBinsForRadius(tt.radius); got != tt.want {
+			t.Errorf("%vx%v/%dx%d: MaxBinsForRadius(%v) = %d, want %d", tt.szx, tt.szy, tt.xdiv, tt.ydiv, tt.radius, got, tt.want)
+		}
+	}
+
+	// No query visits more bins.
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	for i := 0; i < 100; i++ {
+		x, y, radius := float64(i%10)+0.37, float64(i/10)+0.61, float64(i%7)*0.7
+		db.ForEachWithinRadiusProgress(x, y, radius, func(int, float64) {}, func(_, total int) {
+			if maxBins := db.MaxBinsForRadius(radius); total > maxBins {
+				t.Fatalf("query (%v, %v, %v) visits %d bins, MaxBinsForRadius = %d", x, y, radius, total, maxBins)
+			}
+		})
+	}
+}
+
+func TestEmptiestBinNear(t *testing.T) {
+	// 5x5 bins of size 2x2, occupancy (iy rows from top to bottom):
+	//
+	//	iy=4: 0 0 3 3 3
+	//	iy=3: 3 3 3 3 3
+	//	iy=2: 3 2 3 1 3
+	//	iy=1: 3 3 3 3 3
+	//	iy=0: 3 3 3 3 3
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	id := 0
+	fill := func(ix, iy, n int) {
+		for k := 0; k < n; k++ {
+			db.Attach(id, float64(ix)*2+0.5+float64(k)*0.4, float64(iy)*2+1)
+			id++
+		}
+	}
+	for ix := 0; ix < 5; ix++ {
+		for iy := 0; iy < 5; iy++ {
+			n := 3
+			switch {
+			case iy == 4 && ix < 2:
+				n = 0
+			case ix == 1 && iy == 2:
+				n = 2
+			case ix == 3 && iy == 2:
+				n = 1
+			}
+			fill(ix, iy, n)
+		}
+	}
+
+	var tests = []struct {
+		x, y                    float64
+		ring                    int
+		wantX, wantY, wantCount int
+	}{
+		{5, 5, 0, 2, 2, 3},
+		{5, 5, 1, 3, 2, 1},
+		{3, 5, 1, 1, 2, 2},
+		{5, 5, 2, 1, 4, 0}, // (1,4) is closer than (0,4)
+		{1, 5, 2, 0, 4, 0},
+		{2, 5, 2, 0, 4, 0}, // exact tie, the first bin wins
+		{1, 9, 1, 0, 4, 0},
+		{1.5, 9, 1, 0, 4, 0},
+		{2.5, 9, 1, 1, 4, 0},
+		{-10, -10, 0, 0, 0, 3}, // clamped to the super-brick
+	}
+	for _, tt := range tests {
+		ix, iy, count := db.EmptiestBinNear(tt.x, tt.y, tt.ring)
+		if ix != tt.wantX || iy != tt.wantY || count != tt.wantCount {
+			t.Errorf("EmptiestBinNear(%v, %v, %d) = (%d, %d, %d), want (%d, %d, %d)",
+				tt.x, tt.y, tt.ring, ix, iy, count, tt.wantX, tt.wantY, tt.wantCount)
+		}
+	}
+}
+
+func TestKNearestBins(t *testing.T) {
+	// 10x10 bins of size 1x1.
+	db := NewDB[int](0, 0, 10, 10, 10, 10)
+	occupied := [][2]int{{5, 5}, {6, 5}, {5, 7}, {3, 3}, {7, 7}, {0, 9}, {9, 0}}
+	for i, b := range occupied {
+		db.Attach(i, float64(b[0])+0.5, float64(b[1])+0.5)
+	}
+	db.Attach(100, 20, 20) // the other bin isn't considered
+
+	var tests = []struct {
+		x, y float64
+		k    int
+		want [][2]int
+	}{
+		{5.5, 5.5, 1, [][2]int{{5, 5}}},
+		{5.5, 5.5, 3, [][2]int{{5, 5}, {6, 5}, {5, 7}}},
+		{5.5, 5.5, 5, [][2]int{{5, 5}, {6, 5}, {5, 7}, {3, 3}, {7, 7}}}, // (3,3) and (7,7) are tied
+		{4.5, 6.5, 2, [][2]int{{5, 5}, {5, 7}}},                         // (5,5) and (5,7) are tied
+		{1.5, 1.5, 2, [][2]int{{3, 3}, {5, 5}}},
+		{0.5, 0.5, 100, [][2]int{{3, 3}, {5, 5}, {6, 5}, {5, 7}, {0, 9}, {9, 0}, {7, 7}}},
+		{-5, 15, 1, [][2]int{{0, 9}}}, // clamped to (0,9)
+		{5.5, 5.5, 0, nil},
+	}
+	for _, tt := range tests {
+		got := db.KNearestBins(tt.x, tt.y, tt.k)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("KNearestBins(%v, %v, %d) = %v, want %v", tt.x, tt.y, tt.k, got, tt.want)
+		}
+	}
+
+	if got := NewDB[int](0, 0, 10, 10, 10, 10).KNearestBins(5, 5, 3); len(got) != 0 {
+		t.Errorf("KNearestBins() on an empty database = %v, want none", got)
+	}
+}
+
+func TestDetachBin(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	// Bin (2,2) goes from (4,4) to (6,6).
+	db.Attach(1, 4, 4)
+	db.Attach(2, 5, 5)
+	db.Attach(3, 5.99, 4.5)
+	db.Attach(4, 6, 5)  // bin (3,2)
+	db.Attach(5, 3, 3)  // bin (1,1)
+	db.Attach(6, -1, 5) // other bin
+
+	if n := db.DetachBin(2, 2); n != 3 {
+		t.Errorf("DetachBin(2, 2) = %d, want 3", n)
+	}
+	if db.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", db.Len())
+	}
+	ids := make(idset)
+	db.ForEachObject(ids.storeID)
+	for id := 1; id <= 6; id++ {
+		ids.assertIsContained(t, id, id > 3)
+	}
+
+	if n := db.DetachBin(2, 2); n != 0 {
+		t.Errorf("second DetachBin(2, 2) = %d, want 0", n)
+	}
+
+	for _, c := range [][2]int{{-1, 0}, {0, -1}, {5, 0}, {0, 5}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("DetachBin(%d, %d) didn't panic", c[0], c[1])
+				}
+			}()
+			db.DetachBin(c[0], c[1])
+		}()
+	}
+}
+
+func TestBinGeneration(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	gen := db.BinGeneration(1, 1)
+
+	// checkBump checks whether the generation of bin (1,1) has changed.
+	checkBump := func(what string, want bool) {
+		t.Helper()
+		g := db.BinGeneration(1, 1)
+		if changed := g != gen; changed != want {
+			t.Errorf("%s: generation changed = %t, want %t", what, changed, want)
+		}
+		if g < gen {
+			t.Errorf("%s: generation decreased from %d to %d", what, gen, g)
+		}
+		gen = g
+	}
+
+	p := db.Attach(1, 3, 3)
+	checkBump("attach", true)
+	db.Update(p, 2.5, 3.5)
+	checkBump("same-bin move", false)
+	q := db.Attach(2, 7, 7)
+	checkBump("attach elsewhere", false)
+	db.Update(q, 3, 2)
+	checkBump("move in", true)
+	db.Update(q, -1, 2)
+	checkBump("move out", true)
+	db.Detach(p)
+	checkBump("detach", true)
+	db.Detach(p)
+	checkBump("second detach", false)
+	db.Regrid(5, 5)
+	checkBump("regrid", true)
+
+	if g := db.BinGeneration(4, 4); g < gen {
+		t.Errorf("untouched bin generation %d is lower than before Regrid", g)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("BinGeneration(5, 0) didn't panic")
+		}
+	}()
+	db.BinGeneration(5, 0)
+}
@@ -0,0 +1,83 @@
+package lq
+
+// boundedHeap retains the k smallest elements, according to less, among all
+// the elements pushed into it.
+//
+// It's implemented as a binary max-heap so that the greatest retained element,
+// the first to be evicted, is always at the root.
+type boundedHeap[E any] struct {
+	k     int
+	less  func(a, b E) bool
+	elems []E
+}
+
+func newBoundedHeap[E any](k int, less func(a, b E) bool) *boundedHeap[E] {
+	return &boundedHeap[E]{k: k, less: less, elems: make([]E, 0, k)}
+}
+
+// full reports whether the heap holds k elements.
+func (h *boundedHeap[E]) full() bool {
+	return len(h.elems) == h.k
+}
+
+// max returns the greatest retained element. The heap must not be empty.
+func (h *boundedHeap[E]) max() E {
+	return h.elems[0]
+}
+
+// push pushes e into the heap. If the heap is full, e either replaces the
+// greatest retained element if it's smaller, or is discarded.
+func (h *boundedHeap[E]) push(e E) {
+	if h.k <= 0 {
+		return
+	}
+	if len(h.elems) < h.k {
+		h.elems = append(h.elems, e)
+		h.up(len(h.elems) - 1)
+		return
+	}
+	if h.less(e, h.elems[0]) {
+		h.elems[0] = e
+		h.down(0, len(h.elems))
+	}
+}
+
+// sorted returns the retained elements in ascending order. The heap is empty
+// after the call.
+func (h *boundedHeap[E]) sorted() []E {
+	elems := h.elems
+	for n := len(elems) - 1; n > 0; n-- {
+		elems[0], elems[n] = elems[n], elems[0]
+		h.down(0, n)
+	}
+	h.elems = h.elems[:0:0]
+	return elems
+}
+
+func (h *boundedHeap[E]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(h.elems[parent], h.elems[i]) {
+			break
+		}
+		h.elems[parent], h.elems[i] = h.elems[i], h.elems[parent]
+		i = parent
+	}
+}
+
+func (h *boundedHeap[E]) down(i, n int) {
+	for {
+		greatest := i
+		if l := 2*i + 1; l < n && h.less(h.elems[greatest], h.elems[l]) {
+			greatest = l
+		}
+		if r := 2*i + 2; r < n && h.less(h.elems[greatest], h.elems[r]) {
+			greatest = r
+		}
+		if greatest == i {
+			return
+		}
+		h.elems[i], h.elems[greatest] = h.elems[greatest], h.elems[i]
+		i = greatest
+	}
+}
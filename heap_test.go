@@ -0,0 +1,39 @@
+package lq
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBoundedHeap(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	less := func(a, b int) bool { return a < b }
+
+	for _, k := range []int{0, 1, 2, 5, 50, 200} {
+		vals := make([]int, 100)
+		for i := range vals {
+			vals[i] = rng.Intn(50)
+		}
+
+		h := newBoundedHeap(k, less)
+		for _, v := range vals {
+			h.push(v)
+		}
+		got := h.sorted()
+
+		sort.Ints(vals)
+		want := vals
+		if k < len(vals) {
+			want = vals[:k]
+		}
+		if len(got) != len(want) {
+			t.Fatalf("k=%d: got %d elements, want %d", k, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("k=%d: got %v, want %v", k, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package lq
+
+// Handle is an opaque handle on an object inserted into a SpatialIndex.
+type Handle any
+
+// SpatialIndex is a minimal spatial index interface.
+//
+// Programming against SpatialIndex rather than DB allows swapping the spatial
+// backend, for example for a quadtree, without changing the calling code.
+type SpatialIndex[T comparable] interface {
+	// Insert inserts an object at a given location and returns its handle.
+	Insert(obj T, x, y float64) Handle
+	// Remove removes the object of the given handle.
+	Remove(h Handle)
+	// Move moves the object of the given handle to a new location.
+	Move(h Handle, x, y float64)
+	// Query calls f with each object within radius of (x,y), and its squared
+	// distance to (x,y).
+	Query(x, y, radius float64, f func(obj T, sqDist float64))
+	// Nearest returns the object nearest to (x,y) yet within radius, ignored
+	// aside, and true, or the zero value of T and false if there's none.
+	Nearest(x, y, radius float64, ignored T) (T, bool)
+}
+
+// AsSpatialIndex returns db as a SpatialIndex.
+//
+// Insert, Remove, Move, Query and Nearest respectively map to Attach, Detach,
+// Update, ForEachWithinRadius and FindNearestInRadius. The handles are the
+// proxies of the objects, passing a handle returned by another SpatialIndex
+// implementation panics.
+func AsSpatialIndex[T comparable](db *DB[T]) SpatialIndex[T] {
+	return dbIndex[T]{db: db}
+}
+
+// dbIndex adapts a DB to the SpatialIndex interface.
+type dbIndex[T comparable] struct {
+	db *DB[T]
+}
+
+func (idx dbIndex[T]) Insert(obj T, x, y float64) Handle {
+	return idx.db.Attach(obj, x, y)
+}
+
+func (idx dbIndex[T]) Remove(h Handle) {
+	idx.db.Detach(h.(*Proxy[T]))
+}
+
+func (idx dbIndex[T]) Move(h Handle, x, y float64) {
+	idx.db.Update(h.(*Proxy[T]), x, y)
+}
+
+func (idx dbIndex[T]) Query(x, y, radius float64, f func(obj T, sqDist float64)) {
+	idx.db.ForEachWithinRadius(x, y, radius, f)
+}
+
+func (idx dbIndex[T]) Nearest(x, y, radius float64, ignored T) (T, bool) {
+	return idx.db.FindNearestInRadius(x, y, radius, ignored)
+}
@@ -0,0 +1,41 @@
+package lq
+
+import "testing"
+
+func TestAsSpatialIndex(t *testing.T) {
+	var idx SpatialIndex[string] = AsSpatialIndex(NewDB[string](0, 0, 10, 10, 5, 5))
+
+	a := idx.Insert("a", 1, 1)
+	idx.Insert("b", 2, 1)
+	c := idx.Insert("c", 8, 8)
+
+	query := func(x, y, r float64) map[string]bool {
+		res := make(map[string]bool)
+		idx.Query(x, y, r, func(obj string, _ float64) { res[obj] = true })
+		return res
+	}
+
+	if got := query(1, 1, 1.5); len(got) != 2 || !got["a"] || !got["b"] {
+		t.Errorf("Query() = %v, want a and b", got)
+	}
+	if got, ok := idx.Nearest(1, 1, 5, "a"); !ok || got != "b" {
+		t.Errorf("Nearest() = %q, %t, want b", got, ok)
+	}
+
+	idx.Move(c, 1.2, 1.2)
+	if got, ok := idx.Nearest(1.3, 1.3, 5, ""); !ok || got != "c" {
+		t.Errorf("Nearest() after Move = %q, %t, want c", got, ok)
+	}
+
+	idx.Remove(a)
+	if got := query(1, 1, 1.5); len(got) != 2 || !got["b"] || !got["c"] {
+		t.Errorf("Query() after Remove = %v, want b and c", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Remove with a foreign handle didn't panic")
+		}
+	}()
+	idx.Remove(42)
+}
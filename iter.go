@@ -0,0 +1,253 @@
+package lq
+
+import "sort"
+
+// ForEachProxy applies a user-supplied function to the proxies of all objects
+// in the database, regardless of locality.
+//
+// Contrary to ForEachObject, f is allowed to modify the database: in
+// particular f can Update or Detach the proxy it's been called with. Note
+// however that a proxy moved by f to a bin which has not been visited yet will
+// be visited again. If that matters, collect the proxies first and update
+// them afterwards.
+func (db *DB[T]) ForEachProxy(f func(p *Proxy[T])) {
+	visit := func(cp *Proxy[T]) {
+		for cp != nil {
+			// Capture next before calling f, which may move cp elsewhere.
+			next := cp.next
+			f(cp)
+			cp = next
+		}
+	}
+	for i := range db.bins {
+		visit(db.bins[i])
+	}
+	visit(db.other)
+}
+
+// WalkAction tells Walk what to do with the proxy it has just visited.
+type WalkAction int
+
+const (
+	// Keep leaves the proxy in the database.
+	Keep WalkAction = iota
+	// Detach detaches the proxy from the database.
+	Detach
+)
+
+// Walk applies a user-supplied function to the proxies of all objects in the
+// database, regardless of locality, and detaches the proxies for which f
+// returns Detach.
+//
+// This is the safe way of culling objects during a traversal: each proxy is
+// detached once its successor has been captured, so the traversal isn't
+// disrupted. As with ForEachProxy, f may also Update the proxy it's been
+// called with.
+func (db *DB[T]) Walk(f func(p *Proxy[T]) WalkAction) {
+	db.mustNotBeFrozen("Walk")
+
+	visit := func(cp *Proxy[T]) {
+		for cp != nil {
+			next := cp.next
+			if f(cp) == Detach {
+				db.detach(cp)
+			}
+			cp = next
+		}
+	}
+	for i := range db.bins {
+		visit(db.bins[i])
+	}
+	visit(db.other)
+	db.checkOtherThreshold()
+}
+
+// ForEachObjectMortonOrder applies a user-supplied function to all objects in
+// the database, regardless of locality, visiting bins in Morton order.
+//
+// Morton order (or Z-order) visits bins along a Z-shaped space-filling curve,
+// so that bins close to each other in 2D tend to be visited one after the
+// other, contrary to the index order of ForEachObject. Bin (ix,iy) is visited
+// at the position given by interleaving the bits of ix (even bits) and iy (odd
+// bits). Objects outside of the super-brick are visited last. f gets
+// called with a squared distance of 0. Sorting the bins allocates and costs
+// O(n log n), n being the number of bins.
+func (db *DB[T]) ForEachObjectMortonOrder(f Func[T]) {
+	order := make([]int, len(db.bins))
+	codes := make([]uint64, len(db.bins))
+	for ix := 0; ix < db.xdiv; ix++ {
+		for iy := 0; iy < db.ydiv; iy++ {
+			i := db.coordsToIndex(ix, iy)
+			order[i] = i
+			codes[i] = mortonCode(ix, iy)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool { return codes[order[i]] < codes[order[j]] })
+
+	for _, i := range order {
+		db.bins[i].traverseBin(f)
+	}
+	db.other.traverseBin(f)
+}
+
+// ForEachObjectCanonical applies a user-supplied function to all objects in
+// the database, regardless of locality, in a canonical order.
+//
+// Bins are visited row by row, that is by increasing iy then by increasing ix,
+// whatever the index order (see WithIndexOrder), and objects outside of the
+// super-brick are visited last. Within each bin objects are visited by
+// increasing sequence number (see Proxy.Seq). The order thus only depends on
+// the locations of the objects and on the order in which they've been
+// attached, not on the order in which they've been updated, which makes it
+// suitable for byte-stable serialization. f gets called with a squared
+// distance of 0. Sorting the objects of each bin allocates.
+func (db *DB[T]) ForEachObjectCanonical(f Func[T]) {
+	var buf []*Proxy[T]
+	visit := func(head *Proxy[T]) {
+		buf = buf[:0]
+		for cp := head; cp != nil; cp = cp.next {
+			buf = append(buf, cp)
+		}
+		sort.Slice(buf, func(i, j int) bool { return buf[i].seq < buf[j].seq })
+		for _, cp := range buf {
+			f(cp.object, 0)
+		}
+	}
+
+	for iy := 0; iy < db.ydiv; iy++ {
+		for ix := 0; ix < db.xdiv; ix++ {
+			visit(db.bins[db.coordsToIndex(ix, iy)])
+		}
+	}
+	visit(db.other)
+}
+
+// mortonCode returns the Morton code of the bin (ix,iy), that is the bits of ix
+// and iy interleaved, ix bits being the even ones.
+func mortonCode(ix, iy int) uint64 {
+	return spreadBits(uint32(ix)) | spreadBits(uint32(iy))<<1
+}
+
+// spreadBits spreads the bits of v so that they occupy the even bits of the
+// result.
+func spreadBits(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// ForEachObjectWithBin applies a user-supplied function to all objects in the
+// database, regardless of locality, telling which bin each object is in.
+//
+// f gets called with the coordinates of the object's bin, or, for objects
+// outside of the super-brick, with inOther set and both coordinates set to -1.
+func (db *DB[T]) ForEachObjectWithBin(f func(obj T, ix, iy int, inOther bool)) {
+	for ix := 0; ix < db.xdiv; ix++ {
+		for iy := 0; iy < db.ydiv; iy++ {
+			for cp := db.bins[db.coordsToIndex(ix, iy)]; cp != nil; cp = cp.next {
+				f(cp.object, ix, iy, false)
+			}
+		}
+	}
+	for cp := db.other; cp != nil; cp = cp.next {
+		f(cp.object, -1, -1, true)
+	}
+}
+
+// Proxies returns the proxies of all objects in the database.
+//
+// The slice is allocated anew at each call, its length is Len(). Proxies are
+// ordered by bin, then by their order in each bin, which depends on the order
+// in which objects have been attached and updated; objects outside of the
+// super-brick come last.
+func (db *DB[T]) Proxies() []*Proxy[T] {
+	return db.appendProxies(make([]*Proxy[T], 0, db.n))
+}
+
+// ProxyBin returns the coordinates of the bin an object currently is in.
+//
+// As with ForEachObjectWithBin, inOther is set and both coordinates are -1 if
+// the object is outside of the super-brick. They are -1 as well, with inOther
+// unset, if p is not attached to the database.
+func (db *DB[T]) ProxyBin(p *Proxy[T]) (ix, iy int, inOther bool) {
+	switch p.bin {
+	case nil:
+		return -1, -1, false
+	case &db.other:
+		return -1, -1, true
+	}
+	// Bin coordinates are recomputed from the object's location, which is
+	// simpler than deriving them from the position of p.bin in db.bins.
+	ix, iy = db.binCoords(p.x, p.y)
+	return ix, iy, false
+}
+
+// ForEachObjectWithDist applies a user-supplied function to all objects in the
+// database, regardless of locality, like ForEachObject. Unlike ForEachObject,
+// f is called with the squared distance from (x,y) to each object, which is
+// NaN for objects whose location isn't set (see AttachDeferred).
+func (db *DB[T]) ForEachObjectWithDist(x, y float64, f Func[T]) {
+	for _, head := range db.bins {
+		head.traverseBinWithDist(x, y, f)
+	}
+	db.other.traverseBinWithDist(x, y, f)
+}
+
+// ForEachWithinRadiusBruteForce applies f to all objects within a certain
+// locality, like ForEachWithinRadius, but it tests every object of the
+// database rather than only those in the bins overlapping the search circle.
+//
+// It's much slower than ForEachWithinRadius, and only meant to be a reference
+// implementation to test its results against, for example in differential
+// tests or to debug an unexpected result. Objects are visited in unspecified
+// order.
+func (db *DB[T]) ForEachWithinRadiusBruteForce(x, y, radius float64, f Func[T]) {
+	sqRadius := radius * radius
+	for _, head := range db.bins {
+		traverseBinWithinRadius(head, x, y, sqRadius, f)
+	}
+	traverseBinWithinRadius(db.other, x, y, sqRadius, f)
+}
+
+// ForEachOther applies a user-supplied function to all objects in the "other"
+// bin, that is objects outside of the super-brick and objects whose location
+// isn't set yet (see AttachDeferred). f is called with a squared distance of 0.
+func (db *DB[T]) ForEachOther(f Func[T]) {
+	db.other.traverseBin(f)
+}
+
+// appendProxies appends the proxies of all objects in the database to dst and
+// returns the resulting slice.
+func (db *DB[T]) appendProxies(dst []*Proxy[T]) []*Proxy[T] {
+	collect := func(cp *Proxy[T]) {
+		for ; cp != nil; cp = cp.next {
+			dst = append(dst, cp)
+		}
+	}
+	for i := range db.bins {
+		collect(db.bins[i])
+	}
+	collect(db.other)
+	return dst
+}
+
+// ForEachUpdate moves all objects in the database, in one pass.
+//
+// f gets called once for each object, with its current location, and returns
+// the object's new location. Objects are then re-binned according to their
+// new locations, each object being visited exactly once. This provides a
+// simple "simulate one step" primitive.
+func (db *DB[T]) ForEachUpdate(f func(obj T, x, y float64) (newX, newY float64)) {
+	db.mustNotBeFrozen("ForEachUpdate")
+
+	// Collect proxies first so that objects moving to bins that have not been
+	// visited yet aren't visited twice.
+	for _, p := range db.appendProxies(make([]*Proxy[T], 0, db.n)) {
+		x, y := f(p.object, p.x, p.y)
+		db.Update(p, x, y)
+	}
+}
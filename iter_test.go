@@ -0,0 +1,339 @@
+package lq
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestForEachProxy(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	pos := map[int][2]float64{
+		1: {1, 1},
+		2: {1.5, 1.5},
+		3: {5, 5},
+		4: {9, 9},
+		5: {-1, -1},
+	}
+	for id, p := range pos {
+		db.Attach(id, p[0], p[1])
+	}
+
+	// Detach object 2 and move all the others by (-1, -1), some of them
+	// thus move to bins which have already been visited.
+	visited := make(idset)
+	db.ForEachProxy(func(p *Proxy[int]) {
+		id := p.Object()
+		visited.storeID(id, 0)
+
+		x, y := p.Location()
+		if x != pos[id][0] || y != pos[id][1] {
+			t.Errorf("object %d: Location() = (%v, %v), want %v", id, x, y, pos[id])
+		}
+		if id == 2 {
+			db.Detach(p)
+			return
+		}
+		db.Update(p, x-1, y-1)
+	})
+	for id := range pos {
+		visited.assertContains(t, id)
+	}
+
+	if db.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", db.Len())
+	}
+	ids := make(idset)
+	db.ForEachWithinRadius(0, 0, 0.5, ids.storeID)
+	ids.assertContains(t, 1)
+	ids = make(idset)
+	db.ForEachWithinRadius(4, 4, 0.5, ids.storeID)
+	ids.assertContains(t, 3)
+	ids = make(idset)
+	db.ForEachObject(ids.storeID)
+	ids.assertNotContains(t, 2)
+}
+
+func TestForEachObjectWithBin(t *testing.T) {
+	type bin struct {
+		ix, iy  int
+		inOther bool
+	}
+
+	db := NewDB[int](0, 0, 10, 10, 5, 2)
+	db.Attach(1, 1, 1)
+	db.Attach(2, 9, 1)
+	db.Attach(3, 3, 7)
+	db.Attach(4, -1, 5)
+	db.Attach(5, 5, 12)
+	db.Attach(6, 9.9, 9.9)
+
+	want := map[int]bin{
+		1: {0, 0, false},
+		2: {4, 0, false},
+		3: {1, 1, false},
+		4: {-1, -1, true},
+		5: {-1, -1, true},
+		6: {4, 1, false},
+	}
+	got := make(map[int]bin)
+	db.ForEachObjectWithBin(func(id, ix, iy int, inOther bool) {
+		if _, ok := got[id]; ok {
+			t.Errorf("object %d visited twice", id)
+		}
+		got[id] = bin{ix, iy, inOther}
+	})
+	if len(got) != len(want) {
+		t.Errorf("visited %d objects, want %d", len(got), len(want))
+	}
+	for id, b := range want {
+		if got[id] != b {
+			t.Errorf("object %d: got %+v, want %+v", id, got[id], b)
+		}
+	}
+}
+
+func TestForEachUpdate(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	pos := map[int][2]float64{
+		1: {1, 1},
+		2: {3, 1},
+		3: {5, 5},
+		4: {9, 9},
+		5: {-3, 1},
+	}
+	for id, p := range pos {
+		db.Attach(id, p[0], p[1])
+	}
+
+	// Move all objects by (+2, +0), that is one bin to the right.
+	visits := make(map[int]int)
+	db.ForEachUpdate(func(id int, x, y float64) (float64, float64) {
+		visits[id]++
+		if x != pos[id][0] || y != pos[id][1] {
+			t.Errorf("object %d: got location (%v, %v), want %v", id, x, y, pos[id])
+		}
+		return x + 2, y
+	})
+	for id := range pos {
+		if visits[id] != 1 {
+			t.Errorf("object %d visited %d times, want 1", id, visits[id])
+		}
+	}
+
+	type bin struct {
+		ix, iy  int
+		inOther bool
+	}
+	want := map[int]bin{
+		1: {1, 0, false},
+		2: {2, 0, false},
+		3: {3, 2, false},
+		4: {-1, -1, true},
+		5: {-1, -1, true},
+	}
+	db.ForEachObjectWithBin(func(id, ix, iy int, inOther bool) {
+		if got := (bin{ix, iy, inOther}); got != want[id] {
+			t.Errorf("object %d: got bin %+v, want %+v", id, got, want[id])
+		}
+	})
+	if db.OtherCount() != 2 {
+		t.Errorf("OtherCount() = %d, want 2", db.OtherCount())
+	}
+}
+
+func TestProxyBin(t *testing.T) {
+	type bin struct {
+		ix, iy  int
+		inOther bool
+	}
+
+	db := NewDB[int](0, 0, 10, 10, 5, 2)
+	p := db.Attach(1, 1, 1)
+	check := func(want bin) {
+		t.Helper()
+		ix, iy, inOther := db.ProxyBin(p)
+		if got := (bin{ix, iy, inOther}); got != want {
+			t.Errorf("ProxyBin() = %v, want %v", got, want)
+		}
+	}
+
+	check(bin{0, 0, false})
+	db.Update(p, 9.9, 5)
+	check(bin{4, 1, false})
+	db.Update(p, 4, 4.99)
+	check(bin{2, 0, false})
+	db.Update(p, 10, 5)
+	check(bin{-1, -1, true})
+	db.Detach(p)
+	check(bin{-1, -1, false})
+}
+
+func TestProxies(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	if got := db.Proxies(); len(got) != 0 {
+		t.Errorf("empty database: len(Proxies()) = %d, want 0", len(got))
+	}
+
+	want := make(map[*Proxy[int]]bool)
+	for i, loc := range [][2]float64{{1, 1}, {1.5, 1.5}, {5, 5}, {9, 9}, {-1, -1}, {12, 3}} {
+		want[db.Attach(i, loc[0], loc[1])] = true
+	}
+	db.Detach(db.Attach(10, 5, 5))
+
+	got := db.Proxies()
+	if len(got) != db.Len() {
+		t.Errorf("len(Proxies()) = %d, want Len() = %d", len(got), db.Len())
+	}
+	for _, p := range got {
+		if !want[p] {
+			t.Errorf("unexpected proxy for object %d", p.Object())
+		}
+		delete(want, p)
+	}
+	if len(want) != 0 {
+		t.Errorf("%d proxies missing", len(want))
+	}
+}
+
+func TestMortonCode(t *testing.T) {
+	var tests = []struct {
+		ix, iy int
+		want   uint64
+	}{
+		{0, 0, 0},
+		{1, 0, 1},
+		{0, 1, 2},
+		{1, 1, 3},
+		{2, 0, 4},
+		{3, 3, 15},
+		{5, 6, 0b111001},
+		{0xffff, 0, 0x55555555},
+		{0, 0xffffffff, 0xaaaaaaaaaaaaaaaa},
+	}
+	for _, tt := range tests {
+		if got := mortonCode(tt.ix, tt.iy); got != tt.want {
+			t.Errorf("mortonCode(%d, %d) = %#b, want %#b", tt.ix, tt.iy, got, tt.want)
+		}
+	}
+}
+
+func TestForEachObjectMortonOrder(t *testing.T) {
+	// 4x3 grid, object ID is 10*ix+iy.
+	db := NewDB[int](0, 0, 4, 3, 4, 3)
+	for ix := 0; ix < 4; ix++ {
+		for iy := 0; iy < 3; iy++ {
+			db.Attach(10*ix+iy, float64(ix)+0.5, float64(iy)+0.5)
+		}
+	}
+	db.Attach(100, -1, -1)
+
+	var got []int
+	db.ForEachObjectMortonOrder(func(id int, _ float64) { got = append(got, id) })
+
+	want := []int{
+		0, 10, 1, 11, // first 2x2 block
+		20, 30, 21, 31, // second 2x2 block
+		2, 12, 22, 32, // row iy=2
+		100,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("visit order = %v, want %v", got, want)
+	}
+}
+
+func TestForEachObjectCanonical(t *testing.T) {
+	pos := map[int][2]float64{
+		1: {0.5, 0.5}, // bin (0,0)
+		2: {0.2, 0.2}, // bin (0,0)
+		3: {2.5, 0.5}, // bin (2,0)
+		4: {1.5, 1.5}, // bin (1,1)
+		5: {0.5, 1.5}, // bin (0,1)
+		6: {1.2, 1.2}, // bin (1,1)
+		7: {-1, 0},    // other bin
+		8: {5, 5},     // other bin
+	}
+	canonical := func(db *DB[int]) []int {
+		var ids []int
+		db.ForEachObjectCanonical(func(id int, _ float64) { ids = append(ids, id) })
+		return ids
+	}
+
+	db1 := NewDB[int](0, 0, 3, 2, 3, 2)
+	for id := 1; id <= 8; id++ {
+		db1.Attach(id, pos[id][0], pos[id][1])
+	}
+
+	// Objects are attached in another order, objects of the same bin being
+	// attached in the same relative order, then moved around.
+	db2 := New[int](WithBounds(0, 0, 3, 2), WithDivisions(3, 2), WithIndexOrder(RowMajor), WithInsertAtTail())
+	proxies := make(map[int]*Proxy[int])
+	for _, id := range []int{3, 7, 4, 1, 8, 6, 5, 2} {
+		proxies[id] = db2.Attach(id, pos[id][0], pos[id][1])
+	}
+	for _, id := range []int{1, 4, 7} {
+		db2.Update(proxies[id], 10, 10)
+		db2.Update(proxies[id], pos[id][0], pos[id][1])
+	}
+
+	want := []int{1, 2, 3, 5, 4, 6, 7, 8}
+	if got := canonical(db1); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("db1 visit order = %v, want %v", got, want)
+	}
+	if got := canonical(db2); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("db2 visit order = %v, want %v", got, want)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	for i := 0; i < 20; i++ {
+		// Some objects share bins, some are outside of the super-brick.
+		db.Attach(i, float64(i)*0.6-1, 5)
+	}
+
+	visited := make(idset)
+	db.Walk(func(p *Proxy[int]) WalkAction {
+		visited.storeID(p.Object(), 0)
+		if p.Object()%2 == 1 {
+			return Detach
+		}
+		return Keep
+	})
+
+	if db.Len() != 10 {
+		t.Errorf("Len() = %d, want 10", db.Len())
+	}
+	ids := make(idset)
+	db.ForEachObject(ids.storeID)
+	for i := 0; i < 20; i++ {
+		visited.assertContains(t, i)
+		ids.assertIsContained(t, i, i%2 == 0)
+	}
+}
+
+func TestForEachObjectWithDist(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 1, 1)
+	db.Attach(2, 4, 5)
+	db.Attach(3, 9, 9)
+	db.Attach(4, -3, 1) // in the other bin
+	db.AttachDeferred(5)
+
+	want := map[int]float64{1: 0, 2: 25, 3: 128, 4: 16}
+	got := make(map[int]float64)
+	db.ForEachObjectWithDist(1, 1, func(id int, sqDist float64) {
+		got[id] = sqDist
+	})
+	if len(got) != 5 {
+		t.Errorf("got %d objects, want 5", len(got))
+	}
+	for id, sqDist := range want {
+		if got[id] != sqDist {
+			t.Errorf("object %d: sqDist = %v, want %v", id, got[id], sqDist)
+		}
+	}
+	if !math.IsNaN(got[5]) {
+		t.Errorf("deferred object: sqDist = %v, want NaN", got[5])
+	}
+}
@@ -26,24 +26,31 @@
 // the super-brick's position, size and subdivisions see NewDB below.
 //
 // Overview of usage: an application using this facility to perform locality
-// queries over objects of type myStruct would first create a database with:
-//  db := NewDB[myObject]()
-// Then, call Attach for each objects to attach to the database. Attach returns
-// a 'proxy' object, which is a link between the user object and its
-// representation in the locality database.
-//  p := db.Attach(obj)
-// When a client object moves, the application calls Update with the new
-// location. Update is a method of the lq.Proxy object, that's why the the proxy
-// object is generally kept within the user object, though it can be managed
-// separately:
-//  db.Update(123, 456)
+// queries over objects of type myObject would first create a database with:
+//
+//	db := lq.NewDB[myObject](xorg, yorg, xsize, ysize, xdiv, ydiv)
+//
+// Then, call Attach for each object to attach to the database, at its
+// location. Attach returns a 'proxy' object, which is a link between the user
+// object and its representation in the locality database.
+//
+//	p := db.Attach(obj, x, y)
+//
+// When a client object moves, the application calls Update with the object's
+// proxy and its new location, that's why the proxy object is generally kept
+// within the user object, though it can be managed separately:
+//
+//	db.Update(p, 123, 456)
+//
 // To perform a query, DB.ForEachWithinRadius is passed a user function which
 // will be called for all client objects in the locality. See Func below for
 // more detail.
-//  func myFunc(obj T, sqDist float64) {
-//      // do something with obj
-//  }
-//  DB.ForEachWithinRadius(x, y, radius, myFunc, nil)
+//
+//	func myFunc(obj myObject, sqDist float64) {
+//		// do something with obj
+//	}
+//	db.ForEachWithinRadius(x, y, radius, myFunc)
+//
 // The DB.FindNearestInRadius function can be used to find a single nearest
 // neighbor using the database. Note that "locality query" is also known as
 // neighborhood query, neighborhood search, near neighbor search, and range
@@ -58,102 +65,492 @@ import "math"
 
 // DB represents the spatial database.
 //
-// Typically one of these would be created (by a call to DB.NewDB)
+// Typically one of these would be created (by a call to NewDB or New)
 // for a given application.
 type DB[T comparable] struct {
 	xorg, yorg float64 // xorg and yorg are the super-brick corner minimum coordinates
 	szx, szy   float64 // length of the edges of the super-brick
 	xdiv, ydiv int     // number of sub-brick divisions in each direction
 
+	// Number of sub-bricks per unit of length in each direction (xdiv/szx and
+	// ydiv/szy), precomputed to convert coordinates into bin coordinates.
+	xscale, yscale float64
+
 	// Actual bins, allocated in a 1D slice (use coordsToIndex to go from bin
-	// coordinates to index in this slice).
-	bins []*Proxy[T]
+	// coordinates to index in this slice). Bin (ix,iy) is at index
+	// ix*xstride+iy*ystride, the strides depending on the index order.
+	bins             []*Proxy[T]
+	order            IndexOrder
+	xstride, ystride int
 
 	// Extra bin for "everything else" (points outside super-brick).
 	other *Proxy[T]
+
+	// Query statistics, nil unless enabled.
+	stats *QueryStats
+
+	// Number of objects in each bin, nil unless live bin statistics are
+	// enabled, and the sum of their squares.
+	liveCounts []int
+	liveSumSq  int
+
+	// Read-optimized copy of the bins, only set when the database is frozen.
+	frozen    []frozenEntry[T]
+	frozenIdx []int
+
+	// Custom equality used to exclude objects from queries, nil for ==.
+	equal func(a, b T) bool
+
+	// Number of attached objects, and how many of those are in the "other" bin.
+	n, nother int
+
+	// Callback invoked when the fraction of objects in the "other" bin exceeds
+	// otherFrac. otherAlerted is set once it's been invoked, until the
+	// fraction falls below the threshold again.
+	otherCb      func(otherCount, total int)
+	otherFrac    float64
+	otherAlerted bool
+
+	// Number of attached objects of each radius, for objects attached with
+	// AttachSized, and the radius of the largest of them.
+	radii     map[float64]int
+	maxRadius float64
+
+	// What to do with objects outside of the super-brick.
+	oob OutOfBoundsPolicy
+
+	// Whether objects are inserted at the tail of their bin (see
+	// WithInsertAtTail).
+	insertAtTail bool
+
+	// Whether queries always search the "other" bin (see
+	// WithAlwaysCheckOther).
+	alwaysOther bool
+
+	// Number of objects below which radius queries test all objects (see
+	// WithBruteForceThreshold). When brute force is enabled, occupied holds
+	// the non-empty bins, "other" aside, as long as there are less than
+	// 2*bruteForce of them. occupiedOK is unset when that's not the case
+	// anymore, occupied is then recomputed when the number of objects falls
+	// below the threshold: the margin amortizes the cost of recomputing it, and
+	// queries never modify the database.
+	bruteForce int
+	occupied   []**Proxy[T]
+	occupiedOK bool
+
+	// Attached objects which expire (see AttachExpiring), by expiry tick.
+	expiring expiryHeap[T]
+
+	// Per-bin generations, the value of clock when an object was last added
+	// to or removed from each bin. clock is incremented at each mutation.
+	gens  []uint64
+	clock uint64
+
+	// Per-bin dirty stamps, nil unless dirty tracking has been enabled by
+	// NewDirtyTracker. A stamp is the value of clock at the last mutation of
+	// a bin, moves within the bin included, otherDirty being the stamp of the
+	// "other" bin.
+	dirty      []uint64
+	otherDirty uint64
+
+	// Sequence number of the last proxy created.
+	seq uint64
 }
 
 // NewDB creates a new database, allocates the bin array, and returns the DB
 // object.
 //
 // The six parameters define the properties of the 'super-brick':
-//  - xorg/yorg: x/y coordinates of one corner of the super-brick, its minimum x
-//    and y extent.
-//  - xsize/ysize: the width and height of the super-brick.
-//  - xdiv/ydiv: the number of subdivisions (sub-bricks) along each axis.
-func NewDB[T comparable](xorg, yorg, xsize, ysize float64, xdiv, divy int) *DB[T] {
-	return &DB[T]{
-		xorg: xorg,
-		yorg: yorg,
-		szx:  xsize,
-		szy:  ysize,
-		xdiv: xdiv,
-		ydiv: divy,
-		bins: make([]*Proxy[T], xdiv*divy),
-	}
+//   - xorg/yorg: x/y coordinates of one corner of the super-brick, its minimum x
+//     and y extent.
+//   - xsize/ysize: the width and height of the super-brick.
+//   - xdiv/ydiv: the number of subdivisions (sub-bricks) along each axis.
+//
+// xsize and ysize must be strictly positive, NewDB panics otherwise. Even tiny
+// super-bricks work, as long as their extent is representable, that is
+// xorg+xsize must be different from xorg (and the same goes for y). Otherwise
+// every object ends up outside of the super-brick.
+//
+// See New for a more flexible way to create a database.
+func NewDB[T comparable](xorg, yorg, xsize, ysize float64, xdiv, ydiv int) *DB[T] {
+	return New[T](WithBounds(xorg, yorg, xsize, ysize), WithDivisions(xdiv, ydiv))
 }
 
+// AllLayers is the layer mask of objects attached with Attach, they belong to
+// all layers.
+const AllLayers uint32 = math.MaxUint32
+
 // Attach attaches a new object to the database and returns a proxy object.
+//
+// The object belongs to all layers (see AttachLayer).
 func (db *DB[T]) Attach(t T, x, y float64) *Proxy[T] {
-	obj := &Proxy[T]{object: t}
+	db.mustNotBeFrozen("Attach")
+	return db.AttachLayer(t, x, y, AllLayers)
+}
+
+// AttachChecked is like Attach but it returns ErrOutOfBounds, instead of
+// panicking, if the database has the Reject policy (see
+// WithOutOfBoundsPolicy) and (x,y) is outside of the super-brick. In that
+// case, no object is attached and the returned proxy is nil.
+func (db *DB[T]) AttachChecked(t T, x, y float64) (*Proxy[T], error) {
+	db.mustNotBeFrozen("AttachChecked")
+	obj := &Proxy[T]{object: t, layer: AllLayers, seq: db.nextSeq()}
+	if err := db.update(obj, x, y); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// AttachLayer attaches a new object belonging to the given layers to the
+// database and returns a proxy object.
+//
+// layer is a bit mask, each bit representing a layer (or a category) of
+// objects. Queries such as ForEachWithinRadiusLayer only consider objects
+// belonging to at least one of the requested layers.
+func (db *DB[T]) AttachLayer(t T, x, y float64, layer uint32) *Proxy[T] {
+	db.mustNotBeFrozen("AttachLayer")
+	obj := &Proxy[T]{object: t, layer: layer, seq: db.nextSeq()}
 	db.Update(obj, x, y)
 	return obj
 }
 
+// AttachIndexed attaches a new object identified by a non-negative index to
+// the database and returns a proxy object.
+//
+// The index typically identifies an entity in an ECS, queries such as
+// ForEachWithinRadiusMask report the indices of the objects they find.
+// AttachIndexed panics if index is negative.
+func (db *DB[T]) AttachIndexed(t T, index int, x, y float64) *Proxy[T] {
+	db.mustNotBeFrozen("AttachIndexed")
+	if index < 0 {
+		panic("lq: AttachIndexed index must be non-negative")
+	}
+	obj := &Proxy[T]{object: t, layer: AllLayers, index: index + 1, seq: db.nextSeq()}
+	db.Update(obj, x, y)
+	return obj
+}
+
+// AttachDeferred attaches a new object whose location isn't known yet to the
+// database and returns a proxy object.
+//
+// The object is kept in the "other" bin until its location is set with
+// SetPosition (or Update), regardless of the out-of-bounds policy. Until then
+// its location is NaN: it's never found by spatial queries, only by those
+// visiting all objects, such as ForEachObject and ForEachOther.
+func (db *DB[T]) AttachDeferred(t T) *Proxy[T] {
+	db.mustNotBeFrozen("AttachDeferred")
+	obj := &Proxy[T]{object: t, x: math.NaN(), y: math.NaN(), layer: AllLayers, seq: db.nextSeq()}
+	db.link(obj, &db.other, db.insertAtTail)
+	db.checkOtherThreshold()
+	return obj
+}
+
+// SetPosition sets the location of an object attached with AttachDeferred, it's
+// equivalent to Update.
+func (db *DB[T]) SetPosition(obj *Proxy[T], x, y float64) {
+	db.Update(obj, x, y)
+}
+
+// nextSeq returns the sequence number of a new proxy.
+func (db *DB[T]) nextSeq() uint64 {
+	db.seq++
+	return db.seq
+}
+
 // Detach detaches the given proxy object from the database.
 func (db *DB[T]) Detach(obj *Proxy[T]) {
-	obj.removeFromBin()
-	return
+	db.mustNotBeFrozen("Detach")
+	db.detach(obj)
+	db.checkOtherThreshold()
+}
+
+// DetachReport detaches the given proxy object from the database, and reports
+// whether it was actually attached.
+//
+// Detaching an already detached proxy is harmless, but it's often the sign of a
+// bug in the application. DetachReport returns false in that case.
+func (db *DB[T]) DetachReport(obj *Proxy[T]) bool {
+	db.mustNotBeFrozen("DetachReport")
+	attached := db.detach(obj)
+	db.checkOtherThreshold()
+	return attached
+}
+
+// DetachAt detaches the given proxy object from the database, and returns the
+// location it had at the time it was detached.
+//
+// This is useful when an object is destroyed, for example to spawn an effect
+// where it was, and saves a separate call to Location.
+func (db *DB[T]) DetachAt(obj *Proxy[T]) (x, y float64) {
+	db.mustNotBeFrozen("DetachAt")
+	x, y = obj.x, obj.y
+	db.detach(obj)
+	db.checkOtherThreshold()
+	return x, y
 }
 
 // Update updates the location of a proxy object in the database.
 //
 // It should be called for each client object every time its location changes.
 // For example, in an animation application, this would be called each frame for
-// every moving object.
+// every moving object. Update panics if the database has the Reject policy (see
+// WithOutOfBoundsPolicy) and (x,y) is outside of the super-brick, use
+// UpdateChecked to handle that case.
 func (db *DB[T]) Update(obj *Proxy[T], x, y float64) {
+	db.mustNotBeFrozen("Update")
+	if err := db.update(obj, x, y); err != nil {
+		panic(err)
+	}
+}
+
+// UpdateChecked is like Update but it returns ErrOutOfBounds, instead of
+// panicking, if the database has the Reject policy and (x,y) is outside of the
+// super-brick. In that case the proxy is left untouched: if it was attached, it
+// stays at its previous location.
+func (db *DB[T]) UpdateChecked(obj *Proxy[T], x, y float64) error {
+	db.mustNotBeFrozen("UpdateChecked")
+	return db.update(obj, x, y)
+}
+
+// update implements Update and UpdateChecked.
+func (db *DB[T]) update(obj *Proxy[T], x, y float64) error {
 	// find bin for new location
 	newBin := db.binForLocation(x, y)
+	if newBin == &db.other && db.oob == Reject {
+		return ErrOutOfBounds
+	}
 
-	// Store location in client object, for future reference.
+	// Has object's changed bin? The location is stored in the client object,
+	// for future reference, once it's been unlinked from its previous bin.
+	if newBin != obj.bin {
+		attached := obj.bin != nil
+		db.unlink(obj)
+		obj.x = x
+		obj.y = y
+		db.link(obj, newBin, db.insertAtTail)
+		if !attached && obj.radius > 0 {
+			db.addRadius(obj.radius)
+		}
+		db.checkOtherThreshold()
+		return nil
+	}
 	obj.x = x
 	obj.y = y
+	if db.dirty != nil {
+		db.clock++
+		db.markDirty(db.binIndex(x, y))
+	}
+	return nil
+}
 
-	// Has object's changed bin?
-	if newBin != obj.bin {
-		obj.removeFromBin()
-		obj.addToBin(newBin)
+// Reattach attaches a proxy object back to the database, at the given
+// location, after its current bin's objects.
+//
+// Attach and Update insert objects at the head of their bin (unless the
+// database has been created with WithInsertAtTail), so detaching the objects
+// of a bin and attaching them back in iteration order reverses their order.
+// Reattach inserts objects at the tail of their bin instead, which keeps
+// iteration order stable through such detach/reattach cycles. obj may still be
+// attached, in which case it's moved to the tail of its new bin. The cost of
+// Reattach is proportional to the number of objects in the bin.
+func (db *DB[T]) Reattach(obj *Proxy[T], x, y float64) {
+	db.mustNotBeFrozen("Reattach")
+	bin := db.binForLocation(x, y)
+	if bin == &db.other && db.oob == Reject {
+		panic(ErrOutOfBounds)
+	}
+
+	attached := obj.bin != nil
+	db.unlink(obj)
+	obj.x = x
+	obj.y = y
+	db.link(obj, bin, true)
+	if !attached && obj.radius > 0 {
+		db.addRadius(obj.radius)
+	}
+	db.checkOtherThreshold()
+}
+
+// Migrate moves a proxy object from the database to another one, dst, at the
+// given location.
+//
+// The proxy is detached from the database, then attached to dst: the same
+// proxy is reused, which allows streaming objects across databases (for
+// example "active" and "dormant" regions of a world) without reallocating
+// them. obj must be attached to the database, or not attached at all. As with
+// Update, Migrate panics if dst has the Reject policy and (x,y) is outside of
+// its super-brick, obj is then left attached to the database.
+func (db *DB[T]) Migrate(obj *Proxy[T], dst *DB[T], x, y float64) {
+	db.mustNotBeFrozen("Migrate")
+	dst.mustNotBeFrozen("Migrate")
+	bin := dst.binForLocation(x, y)
+	if bin == &dst.other && dst.oob == Reject {
+		panic(ErrOutOfBounds)
 	}
+
+	db.detach(obj)
+	db.checkOtherThreshold()
+	obj.x = x
+	obj.y = y
+	dst.link(obj, bin, dst.insertAtTail)
+	if obj.radius > 0 {
+		dst.addRadius(obj.radius)
+	}
+	dst.checkOtherThreshold()
+}
+
+// link adds a proxy object to the given bin, at the head of the bin's list or
+// at its tail, and keeps track of the number of objects in the database.
+func (db *DB[T]) link(obj *Proxy[T], bin **Proxy[T], atTail bool) {
+	if db.occupiedOK && *bin == nil && bin != &db.other {
+		if len(db.occupied) < 2*db.bruteForce {
+			db.occupied = append(db.occupied, bin)
+		} else {
+			db.occupied, db.occupiedOK = db.occupied[:0], false
+		}
+	}
+	if db.liveCounts != nil && bin != &db.other {
+		// From c to c+1 objects: (c+1)² - c² = 2c+1.
+		i := db.binIndex(obj.x, obj.y)
+		db.liveSumSq += 2*db.liveCounts[i] + 1
+		db.liveCounts[i]++
+	}
+	db.binChanged(bin, obj.x, obj.y)
+	if atTail {
+		obj.appendToBin(bin)
+	} else {
+		obj.addToBin(bin)
+	}
+	db.n++
+	if bin == &db.other {
+		db.nother++
+	}
+}
+
+// detach unlinks a proxy object leaving the database, as opposed to one moving
+// to another bin, cancels its expiry (see AttachExpiring) and forgets its
+// radius (see AttachSized). It reports whether the proxy was actually attached.
+func (db *DB[T]) detach(obj *Proxy[T]) bool {
+	if obj.expiry != 0 {
+		db.cancelExpiry(obj)
+	}
+	if obj.bin != nil && obj.radius > 0 {
+		db.removeRadius(obj.radius)
+	}
+	return db.unlink(obj)
+}
+
+// unlink removes a proxy object from its current bin, and keeps track of the
+// number of objects in the database. It reports whether the proxy was actually
+// attached.
+func (db *DB[T]) unlink(obj *Proxy[T]) bool {
+	if obj.bin == nil {
+		return false
+	}
+	db.n--
+	if obj.bin == &db.other {
+		db.nother--
+	}
+	if db.occupiedOK && obj.prev == nil && obj.next == nil && obj.bin != &db.other {
+		db.removeOccupied(obj.bin)
+	}
+	if db.liveCounts != nil && obj.bin != &db.other {
+		// From c to c-1 objects: (c-1)² - c² = -2c+1.
+		i := db.binIndex(obj.x, obj.y)
+		db.liveSumSq -= 2*db.liveCounts[i] - 1
+		db.liveCounts[i]--
+	}
+	db.binChanged(obj.bin, obj.x, obj.y)
+	obj.removeFromBin()
+	if !db.occupiedOK && db.n < db.bruteForce {
+		db.findOccupied()
+	}
+	return true
+}
+
+// Len returns the number of objects attached to the database.
+func (db *DB[T]) Len() int {
+	return db.n
+}
+
+// OtherCount returns the number of objects attached to the database which are
+// outside of the super-brick.
+//
+// These objects don't benefit from the spatial subdivision, a large number of
+// them is a sign that the super-brick should be enlarged or moved.
+func (db *DB[T]) OtherCount() int {
+	return db.nother
 }
 
 // coordsToIndex determines the index into linear bin array given 2D bin
 // indices
 func (db *DB[T]) coordsToIndex(ix, iy int) int {
-	return ix*db.ydiv + iy
+	return ix*db.xstride + iy*db.ystride
 }
 
 // Find the bin ID for a location in space. The location is given in
 // terms of its XY coordinates. The bin ID is a pointer to a pointer
 // to the bin contents list.
 func (db *DB[T]) binForLocation(x, y float64) **Proxy[T] {
-	// If point is outside the super-brick, return the 'other' bin.
-	if x < db.xorg {
+	i := db.binIndex(x, y)
+	if i < 0 {
 		return &(db.other)
 	}
-	if y < db.yorg {
-		return &(db.other)
-	}
-	if x >= db.xorg+db.szx {
-		return &(db.other)
+	return &(db.bins[i])
+}
+
+// binIndex returns the index, in db.bins, of the bin containing the location
+// (x,y), or -1 if the location belongs to the "other" bin.
+func (db *DB[T]) binIndex(x, y float64) int {
+	// Objects without location (see AttachDeferred) stay in the 'other' bin.
+	if x != x || y != y {
+		return -1
 	}
-	if y >= db.yorg+db.szy {
-		return &(db.other)
+
+	// If point is outside the super-brick, return the 'other' bin, unless
+	// points must be clamped to the super-brick border.
+	if db.oob != ClampToEdge {
+		if x < db.xorg {
+			return -1
+		}
+		if y < db.yorg {
+			return -1
+		}
+		if x >= db.xorg+db.szx {
+			return -1
+		}
+		if y >= db.yorg+db.szy {
+			return -1
+		}
 	}
 
-	// Point is inside the super brik, compute the bin coordinates and return that bin.
-	ix := int((x - db.xorg) / db.szx * float64(db.xdiv))
-	iy := int((y - db.yorg) / db.szy * float64(db.ydiv))
-	return &(db.bins[db.coordsToIndex(ix, iy)])
+	// Point is inside the super brik, compute the bin coordinates and return
+	// that bin. Note that multiplying by the precomputed scale may differ by
+	// one ulp from dividing by the bin size, clampBin makes sure that a point
+	// just below the super-brick upper bounds doesn't end up out of it.
+	ix := clampBin((x-db.xorg)*db.xscale, db.xdiv)
+	iy := clampBin((y-db.yorg)*db.yscale, db.ydiv)
+	return db.coordsToIndex(ix, iy)
+}
+
+// toBinCoord converts a fractional bin coordinate into a bin coordinate, for a
+// super-brick with n divisions. Coordinates below 0 or above n are saturated
+// since they would be clipped anyway: this prevents huge coordinates, obtained
+// with tiny super-bricks, from overflowing. Saturating negative coordinates to
+// -1, rather than truncating them toward 0, also ensures that a coordinate
+// just below the super-brick is recognized as outside of it. NaN, obtained at
+// the super-brick border when the bins are so small their scale is infinite,
+// gives 0.
+func toBinCoord(f float64, n int) int {
+	switch {
+	case f < 0:
+		return -1
+	case f > float64(n):
+		return n
+	case f != f:
+		return 0
+	}
+	return int(f)
 }
 
 // Func is the function called, for each proxy object, when iterating over a set
@@ -174,19 +571,22 @@ func (db *DB[T]) ForEachObject(f Func[T]) {
 
 // DetachAll detaches all proxy objects from the database.
 func (db *DB[T]) DetachAll() {
+	db.mustNotBeFrozen("DetachAll")
+
 	for i := range db.bins {
 		pbin := &(db.bins[i])
 		for *pbin != nil {
-			(*pbin).removeFromBin()
+			db.detach(*pbin)
 		}
 	}
 
 	if db.other != nil {
 		pbin := &(db.other)
 		for *pbin != nil {
-			(*pbin).removeFromBin()
+			db.detach(*pbin)
 		}
 	}
+	db.checkOtherThreshold()
 }
 
 // This subroutine of ForEachWithinRadius efficiently traverses a
@@ -195,16 +595,16 @@ func (db *DB[T]) forEachInRadiusClipped(x, y, radius float64, f Func[T], xmin, y
 	sqRadius := radius * radius
 
 	// Loop for x bins across diameter of circle.
-	idx := xmin * db.ydiv
+	idx := db.coordsToIndex(xmin, ymin)
 	for i := xmin; i <= xmax; i++ {
 		// Loop for y bins across diameter of circle.
-		jdx := ymin
+		jdx := idx
 		for j := ymin; j <= ymax; j++ {
 			// Traverse current bin's client object list.
-			traverseBinWithinRadius(db.bins[idx+jdx], x, y, sqRadius, f)
-			jdx++
+			traverseBinWithinRadius(db.bins[jdx], x, y, sqRadius, f)
+			jdx += db.ystride
 		}
-		idx += db.ydiv
+		idx += db.xstride
 	}
 }
 
@@ -226,48 +626,145 @@ func (db *DB[T]) forEachObjectOutside(x, y, radius float64, f Func[T]) {
 // circle of interest. Incremental calculation of index values is used to
 // efficiently traverse the bins of interest.
 func (db *DB[T]) ForEachWithinRadius(x, y, radius float64, f Func[T]) {
-	partlyOut := false
-	completelyOutside := x+radius < db.xorg ||
-		y+radius < db.yorg ||
-		x-radius >= db.xorg+db.szx ||
-		y-radius >= db.yorg+db.szy
-
-	// Is the circle completely outside the "super brick"?
-	if completelyOutside {
+	if db.stats != nil {
+		db.forEachWithinRadiusStats(x, y, radius, f)
+		return
+	}
+	if db.frozenIdx != nil {
+		db.forEachWithinRadiusFrozen(x, y, radius, f)
+		return
+	}
+
+	if db.n < db.bruteForce && db.occupiedOK {
+		// So few objects that testing them all is cheaper than finding the
+		// bins overlapping the circle.
+		sqRadius := radius * radius
+		for _, bin := range db.occupied {
+			traverseBinWithinRadius(*bin, x, y, sqRadius, f)
+		}
+		traverseBinWithinRadius(db.other, x, y, sqRadius, f)
+		return
+	}
+
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+
+	// Map function over outside objects if necessary (if clipped)
+	if outside {
 		db.forEachObjectOutside(x, y, radius, f)
 	}
 
+	if minBinX == 0 && minBinY == 0 && maxBinX == db.xdiv-1 && maxBinY == db.ydiv-1 {
+		// The circle covers the whole grid: traverse the bins in memory order,
+		// without computing bin indices.
+		sqRadius := radius * radius
+		for _, head := range db.bins {
+			traverseBinWithinRadius(head, x, y, sqRadius, f)
+		}
+		return
+	}
+
+	// Map function over objects in bins
+	db.forEachInRadiusClipped(x, y, radius, f, minBinX, minBinY, maxBinX, maxBinY)
+}
+
+// binRange computes the min and max coordinates of the bins overlapping the
+// rectangle [x0,x1]×[y0,y1], clipped to the super-brick. It also reports
+// whether the rectangle extends outside the super-brick, in which case the
+// "other" bin must be searched as well.
+func (db *DB[T]) binRange(x0, y0, x1, y1 float64) (minBinX, minBinY, maxBinX, maxBinY int, outside bool) {
+	// Is the rectangle completely outside the "super brick"?
+	outside = db.alwaysOther ||
+		x1 < db.xorg ||
+		y1 < db.yorg ||
+		x0 >= db.xorg+db.szx ||
+		y0 >= db.yorg+db.szy
+
 	// compute min and max bin coordinates for each dimension
-	minBinX := int(float64(db.xdiv) * (x - radius - db.xorg) / db.szx)
-	minBinY := int(float64(db.ydiv) * (y - radius - db.yorg) / db.szy)
-	maxBinX := int(float64(db.xdiv) * (x + radius - db.xorg) / db.szx)
-	maxBinY := int(float64(db.ydiv) * (y + radius - db.yorg) / db.szy)
+	minBinX = toBinCoord((x0-db.xorg)*db.xscale, db.xdiv)
+	minBinY = toBinCoord((y0-db.yorg)*db.yscale, db.ydiv)
+	maxBinX = toBinCoord((x1-db.xorg)*db.xscale, db.xdiv)
+	maxBinY = toBinCoord((y1-db.yorg)*db.yscale, db.ydiv)
 
 	// clip bin coordinates
 	if minBinX < 0 {
-		partlyOut = true
+		outside = true
 		minBinX = 0
 	}
 	if minBinY < 0 {
-		partlyOut = true
+		outside = true
 		minBinY = 0
 	}
 	if maxBinX >= db.xdiv {
-		partlyOut = true
+		outside = true
 		maxBinX = db.xdiv - 1
 	}
 	if maxBinY >= db.ydiv {
-		partlyOut = true
+		outside = true
 		maxBinY = db.ydiv - 1
 	}
 
-	// Map function over outside objects if necessary (if clipped)
-	if partlyOut {
-		db.forEachObjectOutside(x, y, radius, f)
+	if db.oob == ClampToEdge {
+		// Objects outside of the super-brick are in the border bins, which
+		// must be visited even if the rectangle doesn't overlap them.
+		minBinX, maxBinX = clampRange(minBinX, maxBinX, db.xdiv)
+		minBinY, maxBinY = clampRange(minBinY, maxBinY, db.ydiv)
 	}
+	return minBinX, minBinY, maxBinX, maxBinY, outside
+}
 
-	// Map function over objects in bins
-	db.forEachInRadiusClipped(x, y, radius, f, minBinX, minBinY, maxBinX, maxBinY)
+// clampRange clamps a range of bin coordinates, already clipped on the outer
+// side, so that it contains at least one bin of [0, n).
+func clampRange(lo, hi, n int) (int, int) {
+	if hi < 0 {
+		hi = 0
+	}
+	if lo > n-1 {
+		lo = n - 1
+	}
+	return lo, hi
+}
+
+// findOccupied recomputes the list of non-empty bins.
+func (db *DB[T]) findOccupied() {
+	db.occupied = db.occupied[:0]
+	for i := range db.bins {
+		if db.bins[i] != nil {
+			db.occupied = append(db.occupied, &db.bins[i])
+		}
+	}
+	db.occupiedOK = true
+}
+
+// removeOccupied removes a bin that's becoming empty from the list of
+// non-empty bins.
+func (db *DB[T]) removeOccupied(bin **Proxy[T]) {
+	for i, b := range db.occupied {
+		if b == bin {
+			last := len(db.occupied) - 1
+			db.occupied[i] = db.occupied[last]
+			db.occupied[last] = nil
+			db.occupied = db.occupied[:last]
+			return
+		}
+	}
+}
+
+// SetEquals sets the function used to compare objects when excluding the
+// ignored object from queries such as FindNearestInRadius.
+//
+// By default objects are compared with ==, which compares whole struct values.
+// A custom equality allows for example to exclude an object by its identity
+// even when its other fields differ. Passing nil restores the default.
+func (db *DB[T]) SetEquals(equal func(a, b T) bool) {
+	db.equal = equal
+}
+
+// isIgnored reports whether obj is the ignored object.
+func (db *DB[T]) isIgnored(obj, ignored T) bool {
+	if db.equal != nil {
+		return db.equal(obj, ignored)
+	}
+	return obj == ignored
 }
 
 // FindNearestInRadius searches the database to find the object whose key-point
@@ -275,26 +772,30 @@ func (db *DB[T]) ForEachWithinRadius(x, y, radius float64, f Func[T]) {
 //
 // That is, it finds the object (if any) within a given search circle which is
 // nearest to the circle's center. The ignored argument can be used to exclude
-// an object from consideration. This is useful when looking for the nearest
-// neighbor of an object in the database, since otherwise it would be its own
-// nearest neighbor. The function returns the nearest object and true, or if
-// there was no object with the provided radius, it returns the zero value of T,
-// and false.
+// an object from consideration (see SetEquals). This is useful when looking for
+// the nearest neighbor of an object in the database, since otherwise it would
+// be its own nearest neighbor. Among objects at the same distance, the one
+// attached first wins, so the result doesn't depend on the order of the
+// objects in the bins. The function returns the nearest object and true, or if
+// there was no object with the provided radius, it returns the zero value of
+// T, and false.
 func (db *DB[T]) FindNearestInRadius(x, y, radius float64, ignored T) (T, bool) {
 	nearest := *new(T)
 	minSqDist := math.MaxFloat64
+	var minSeq uint64
 	found := false
 
 	// Map search helper function over all objects within radius.
-	db.ForEachWithinRadius(x, y, radius, func(obj T, sqDist float64) {
-		if ignored == obj {
+	db.forEachWithinRadiusSeq(x, y, radius, func(obj T, sqDist float64, seq uint64) {
+		if db.isIgnored(obj, ignored) {
 			return
 		}
 
-		if sqDist < minSqDist {
+		if sqDist < minSqDist || (sqDist == minSqDist && seq < minSeq) {
 			// Update nearest
 			nearest = obj
 			minSqDist = sqDist
+			minSeq = seq
 			found = true
 		}
 	})
@@ -302,6 +803,84 @@ func (db *DB[T]) FindNearestInRadius(x, y, radius float64, ignored T) (T, bool)
 	return nearest, found
 }
 
+// forEachWithinRadiusSeq is like ForEachWithinRadius but f also receives the
+// sequence number of the proxy of each object.
+func (db *DB[T]) forEachWithinRadiusSeq(x, y, radius float64, f func(obj T, sqDist float64, seq uint64)) {
+	if db.frozenIdx == nil {
+		db.forEachProxyWithinRadius(x, y, radius, func(cp *Proxy[T], sqDist float64) {
+			f(cp.object, sqDist, cp.seq)
+		})
+		return
+	}
+	db.forEachBinWithinRadius(x, y, radius, func(bin int, match func(ox, oy float64) (float64, bool)) {
+		entries := db.frozen[db.frozenIdx[bin]:db.frozenIdx[bin+1]]
+		for i := range entries {
+			if sqDist, ok := match(entries[i].x, entries[i].y); ok {
+				f(entries[i].object, sqDist, entries[i].seq)
+			}
+		}
+	})
+}
+
+// forEachProxyWithinRadius is like ForEachWithinRadius but f receives the
+// proxy of each object. It walks the bins lists, even if the database is
+// frozen.
+func (db *DB[T]) forEachProxyWithinRadius(x, y, radius float64, f func(cp *Proxy[T], sqDist float64)) {
+	db.forEachBinWithinRadius(x, y, radius, func(bin int, match func(ox, oy float64) (float64, bool)) {
+		head := db.other
+		if bin < len(db.bins) {
+			head = db.bins[bin]
+		}
+		for cp := head; cp != nil; cp = cp.next {
+			if sqDist, ok := match(cp.x, cp.y); ok {
+				f(cp, sqDist)
+			}
+		}
+	})
+}
+
+// forEachBinWithinRadius calls visit with the index of each bin overlapping
+// the circle of center (x,y), len(db.bins) standing for the "other" bin, and a
+// match function reporting the squared distance from (x,y) to a location, and
+// whether it's within radius. It records the query statistics, if enabled.
+func (db *DB[T]) forEachBinWithinRadius(x, y, radius float64, visit func(bin int, match func(ox, oy float64) (float64, bool))) {
+	st := db.stats
+	if st != nil {
+		st.Queries++
+	}
+
+	sqRadius := radius * radius
+	match := func(ox, oy float64) (float64, bool) {
+		if st != nil {
+			st.ObjectsTested++
+		}
+		sqDist := (x-ox)*(x-ox) + (y-oy)*(y-oy)
+		if sqDist >= sqRadius {
+			return 0, false
+		}
+		if st != nil {
+			st.Matches++
+		}
+		return sqDist, true
+	}
+	visitBin := func(bin int) {
+		if st != nil {
+			st.BinsVisited++
+		}
+		visit(bin, match)
+	}
+
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	if outside {
+		visitBin(len(db.bins))
+	}
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			visitBin(db.coordsToIndex(i, j))
+		}
+	}
+}
+
 // Proxy is a proxy for a client (application) object in the spatial database.
 //
 // One of these should be created for each client object. This might be included
@@ -318,6 +897,64 @@ type Proxy[T any] struct {
 
 	// Object's location ("key point") used for spatial sorting.
 	x, y float64
+
+	// Bit mask of the layers the object belongs to.
+	layer uint32
+
+	// Radius of the object, for objects attached with AttachSized.
+	radius float64
+
+	// Index of the object plus one, for objects attached with AttachIndexed,
+	// 0 otherwise.
+	index int
+
+	// Sequence number, in the order in which proxies have been created by the
+	// database. Used to break ties deterministically.
+	seq uint64
+
+	// Expiry tick, for objects attached with AttachExpiring, and index of
+	// the proxy in DB.expiring plus one, 0 if the object doesn't expire.
+	expireAt int64
+	expiry   int
+
+	// User data, see SetUserData.
+	userData any
+}
+
+// Object returns the client object associated to the proxy.
+func (cp *Proxy[T]) Object() T {
+	return cp.object
+}
+
+// Location returns the location of the proxy, as given during the last call to
+// Attach or Update.
+func (cp *Proxy[T]) Location() (x, y float64) {
+	return cp.x, cp.y
+}
+
+// Seq returns the sequence number of the proxy.
+//
+// Each proxy created by a database gets a sequence number, starting from 1,
+// greater than those of all the proxies previously created by that database.
+// Sequence numbers give proxies a stable identity, for logging or ordering,
+// even when their objects are equal. A proxy keeps its sequence number when
+// it's detached, or migrated to another database.
+func (cp *Proxy[T]) Seq() uint64 {
+	return cp.seq
+}
+
+// SetUserData associates arbitrary data with the proxy.
+//
+// User data is a side channel, distinct from the client object, for example to
+// keep a render handle along with a game entity without widening T. It's
+// ignored by the database, and kept when the proxy is detached or migrated.
+func (cp *Proxy[T]) SetUserData(data any) {
+	cp.userData = data
+}
+
+// UserData returns the data associated with the proxy by SetUserData, or nil.
+func (cp *Proxy[T]) UserData() any {
+	return cp.userData
 }
 
 // addToBin adds a given client object to a given bin, linking it into the bin
@@ -337,6 +974,25 @@ func (cp *Proxy[T]) addToBin(bin **Proxy[T]) {
 	cp.bin = bin
 }
 
+// appendToBin adds a given client object at the tail of a given bin contents
+// list.
+func (cp *Proxy[T]) appendToBin(bin **Proxy[T]) {
+	cp.next = nil
+	cp.bin = bin
+	if *bin == nil {
+		cp.prev = nil
+		*bin = cp
+		return
+	}
+
+	last := *bin
+	for last.next != nil {
+		last = last.next
+	}
+	last.next = cp
+	cp.prev = last
+}
+
 // removeFromBin removes a given client object from its current bin, unlinking
 // it from the bin contents list.
 func (cp *Proxy[T]) removeFromBin() {
@@ -393,3 +1049,12 @@ func (cp *Proxy[T]) traverseBin(fn Func[T]) {
 		cp = cp.next
 	}
 }
+
+// traverseBinWithDist walks down the proxy list, applying the call-back
+// function to each one, along with its squared distance to (x,y).
+func (cp *Proxy[T]) traverseBinWithDist(x, y float64, fn Func[T]) {
+	for cp != nil {
+		fn(cp.object, (x-cp.x)*(x-cp.x)+(y-cp.y)*(y-cp.y))
+		cp = cp.next
+	}
+}
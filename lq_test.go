@@ -3,6 +3,7 @@ package lq
 import (
 	"fmt"
 	"log"
+	"math"
 	"testing"
 )
 
@@ -113,6 +114,50 @@ func TestRemoveObject(t *testing.T) {
 	}
 }
 
+func TestDetachReport(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+
+	p1 := db.Attach(1, 5, 5)
+	p2 := db.Attach(2, -1, -1)
+
+	if !db.DetachReport(p1) {
+		t.Errorf("first DetachReport(p1) = false, want true")
+	}
+	if db.DetachReport(p1) {
+		t.Errorf("second DetachReport(p1) = true, want false")
+	}
+	if !db.DetachReport(p2) {
+		t.Errorf("first DetachReport(p2) = false, want true")
+	}
+	if db.DetachReport(p2) {
+		t.Errorf("second DetachReport(p2) = true, want false")
+	}
+
+	ids := make(idset)
+	db.ForEachObject(ids.storeID)
+	ids.assertEmpty(t)
+}
+
+func TestDetachAt(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+
+	p1 := db.Attach(1, 5, 5)
+	p2 := db.Attach(2, 1, 1)
+	db.Update(p1, 7, 3)
+	db.Update(p2, -2, 4) // moves to the other bin
+
+	if x, y := db.DetachAt(p1); x != 7 || y != 3 {
+		t.Errorf("DetachAt(p1) = (%v,%v), want (7,3)", x, y)
+	}
+	if x, y := db.DetachAt(p2); x != -2 || y != 4 {
+		t.Errorf("DetachAt(p2) = (%v,%v), want (-2,4)", x, y)
+	}
+
+	ids := make(idset)
+	db.ForEachObject(ids.storeID)
+	ids.assertEmpty(t)
+}
+
 func TestRemoveAllObjects(t *testing.T) {
 	var tests = []struct {
 		orgx, orgy float64
@@ -185,6 +230,137 @@ func TestObjectLocality(t *testing.T) {
 	}
 }
 
+func TestForEachWithinRadiusWholeGrid(t *testing.T) {
+	for _, order := range []IndexOrder{ColumnMajor, RowMajor} {
+		db := New[int](WithBounds(0, 0, 10, 10), WithDivisions(5, 4), WithIndexOrder(order))
+		id := 0
+		for x := 0.5; x < 10; x++ {
+			for y := 0.5; y < 10; y++ {
+				db.Attach(id, x, y)
+				id++
+			}
+		}
+		inside := id
+		db.Attach(id, -5, 5)   // in the other bin, within radius
+		db.Attach(id+1, 50, 5) // in the other bin, out of radius
+
+		// The circle covers the whole super-brick, each object must be visited
+		// once, and only if it's within radius.
+		visits := make(map[int]int)
+		db.ForEachWithinRadius(5, 5, 20, func(id int, _ float64) {
+			visits[id]++
+		})
+		for i := 0; i <= inside; i++ {
+			if visits[i] != 1 {
+				t.Errorf("order %v: object %d visited %d times, want 1", order, i, visits[i])
+			}
+		}
+		if visits[inside+1] != 0 {
+			t.Errorf("order %v: object out of radius visited %d times", order, visits[inside+1])
+		}
+	}
+}
+
+func TestOtherNearBorder(t *testing.T) {
+	// Objects just outside of the super-brick, found by queries centered
+	// inside of it, whose search circle only extends a fraction of a bin past
+	// the border.
+	var tests = []struct {
+		ox, oy float64 // object location
+		cx, cy float64 // search circle center
+	}{
+		{-0.1, 5, 0.5, 5},
+		{5, -0.1, 5, 0.5},
+		{10.1, 5, 9.5, 5},
+		{5, 10.1, 5, 9.5},
+		{-0.1, -0.1, 0.2, 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("object at (%v,%v)", tt.ox, tt.oy), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, tt.ox, tt.oy)
+
+			ids := make(idset)
+			db.ForEachWithinRadius(tt.cx, tt.cy, 1, ids.storeID)
+			ids.assertContains(t, 1)
+
+			if got, ok := db.FindNearestInRadius(tt.cx, tt.cy, 1, 0); !ok || got != 1 {
+				t.Errorf("FindNearestInRadius() = %v, %t, want 1", got, ok)
+			}
+		})
+	}
+}
+
+func TestNonSquareBins(t *testing.T) {
+	// Bins of 10x1 and 1x10.
+	for _, sz := range [][2]float64{{100, 10}, {10, 100}} {
+		db := NewDB[int](0, 0, sz[0], sz[1], 10, 10)
+		bw, bh := sz[0]/10, sz[1]/10
+
+		// Objects on and around the corners of every bin.
+		var pos [][2]float64
+		for i := 0; i <= 10; i++ {
+			for j := 0; j <= 10; j++ {
+				for _, d := range [][2]float64{{0, 0}, {-1e-9, 0}, {0, -1e-9}, {1e-9, 1e-9}, {-0.01, -0.01}} {
+					pos = append(pos, [2]float64{float64(i)*bw + d[0], float64(j)*bh + d[1]})
+				}
+			}
+		}
+		for i, p := range pos {
+			db.Attach(i, p[0], p[1])
+		}
+
+		// Circles clipping bin corners.
+		for i := 0; i <= 10; i++ {
+			for j := 0; j <= 10; j++ {
+				for _, r := range []float64{0.01, 0.3, 0.7, 1.2, 3} {
+					x, y := float64(i)*bw+r*0.7, float64(j)*bh+r*0.7
+					want := make(idset)
+					db.ForEachWithinRadiusBruteForce(x, y, r, want.storeID)
+					got := make(idset)
+					db.ForEachWithinRadius(x, y, r, got.storeID)
+					if len(got) != len(want) {
+						t.Fatalf("%vx%v: ForEachWithinRadius(%v, %v, %v) found %d objects, want %d", sz[0], sz[1], x, y, r, len(got), len(want))
+					}
+					for id := range want {
+						got.assertContains(t, id)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBinRangeNegativeOffsets(t *testing.T) {
+	var tests = []struct {
+		x, y, r          float64 // query circle
+		minBinX, minBinY int
+		outside          bool
+		ox, oy           float64 // object within the circle
+	}{
+		{0.5, 5, 1, 0, 2, true, -0.4, 5},      // left edge half a bin left of xorg
+		{5, 0.5, 1, 2, 0, true, 5, -0.4},      // bottom edge half a bin below yorg
+		{-0.1, 5, 0.05, 0, 2, true, -0.11, 5}, // center left of xorg
+		{5, -0.1, 0.05, 2, 0, true, 5, -0.11}, // center below yorg
+		{-0.1, -0.1, 0.2, 0, 0, true, -0.01, -0.01},
+		{1, 5, 1, 0, 2, false, 0.5, 5}, // left edge exactly on xorg
+		{2.5, 2.5, 0.4, 1, 1, false, 2.5, 2.5},
+	}
+	for _, tt := range tests {
+		db := NewDB[int](0, 0, 10, 10, 5, 5)
+		minBinX, minBinY, _, _, outside := db.binRange(tt.x-tt.r, tt.y-tt.r, tt.x+tt.r, tt.y+tt.r)
+		if minBinX != tt.minBinX || minBinY != tt.minBinY || outside != tt.outside {
+			t.Errorf("binRange around (%v, %v, %v) = min (%d, %d), outside %t, want (%d, %d), %t",
+				tt.x, tt.y, tt.r, minBinX, minBinY, outside, tt.minBinX, tt.minBinY, tt.outside)
+		}
+
+		db.Attach(1, tt.ox, tt.oy)
+		ids := make(idset)
+		db.ForEachWithinRadius(tt.x, tt.y, tt.r, ids.storeID)
+		ids.assertContains(t, 1)
+	}
+}
+
 func TestBinRelinking(t *testing.T) {
 	for i := range []int{1, 2, 3} {
 		db := NewDB[int](0, 0, 10, 10, 5, 5)
@@ -240,3 +416,361 @@ func TestNearestNeighbor(t *testing.T) {
 		})
 	}
 }
+
+func TestDegenerateGrids(t *testing.T) {
+	pts := [][2]float64{
+		{0, 0}, {5, 5}, {9.99, 9.99}, {0, 9.99}, {9.99, 0}, // inside, on the edges
+		{10, 10}, {10, 5}, {5, 10}, {-5, 5}, {5, -5}, // outside
+		{2, 8}, {8, 2}, {4.9, 5.1},
+	}
+
+	var tests = []struct {
+		divx, divy int
+		cx, cy, cr float64
+	}{
+		{1, 1, 5, 5, 0.5},
+		{1, 1, 5, 5, 8},
+		{1, 1, 0, 0, 1},
+		{1, 1, 10, 10, 0.5},
+		{1, 1, -1, 5, 1.1},
+		{1, 1, 20, 20, 1},
+		{1, 5, 5, 5, 0.5},
+		{1, 5, 5, 5, 3.2},
+		{1, 5, 0, 9.99, 1},
+		{1, 5, 10, 5, 0.5},
+		{1, 5, 5, -1, 1.5},
+		{5, 1, 5, 5, 0.5},
+		{5, 1, 5, 5, 3.2},
+		{5, 1, 9.99, 0, 1},
+		{5, 1, 5, 10, 0.5},
+		{5, 1, -1, 5, 1.5},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dx%d grid,c=(%v,%v),r=%v", tt.divx, tt.divy, tt.cx, tt.cy, tt.cr), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, tt.divx, tt.divy)
+			for i, pt := range pts {
+				db.Attach(i, pt[0], pt[1])
+			}
+
+			ids := make(idset)
+			db.ForEachWithinRadius(tt.cx, tt.cy, tt.cr, ids.storeID)
+
+			for i, pt := range pts {
+				dx, dy := pt[0]-tt.cx, pt[1]-tt.cy
+				ids.assertIsContained(t, i, dx*dx+dy*dy < tt.cr*tt.cr)
+			}
+		})
+	}
+}
+
+func TestLen(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+
+	assertLen := func(n, nother int) {
+		t.Helper()
+		if db.Len() != n {
+			t.Errorf("Len() = %d, want %d", db.Len(), n)
+		}
+		if db.OtherCount() != nother {
+			t.Errorf("OtherCount() = %d, want %d", db.OtherCount(), nother)
+		}
+	}
+
+	assertLen(0, 0)
+	p1 := db.Attach(1, 5, 5)
+	p2 := db.Attach(2, -5, 5)
+	p3 := db.Attach(3, 1, 1)
+	assertLen(3, 1)
+
+	db.Update(p1, 5.1, 5.1) // same bin
+	db.Update(p3, 11, 11)   // to "other"
+	assertLen(3, 2)
+	db.Update(p2, 2, 2) // from "other"
+	assertLen(3, 1)
+
+	db.Detach(p1)
+	db.Detach(p1)
+	assertLen(2, 1)
+	db.Update(p1, 3, 3) // re-attach
+	assertLen(3, 1)
+
+	db.DetachAll()
+	assertLen(0, 0)
+}
+
+func TestBinForLocationUpperBound(t *testing.T) {
+	// Points just below the super-brick upper bounds must end up in the last
+	// bins, whatever the rounding of the bin coordinates computation.
+	for _, div := range []int{1, 3, 7, 10, 49} {
+		for _, org := range []float64{-3.3, 0, 0.1, 1e6} {
+			db := NewDB[int](org, org, 10, 10, div, div)
+			x := math.Nextafter(org+10, org)
+			db.Attach(1, x, x)
+
+			if db.OtherCount() != 0 {
+				t.Errorf("div=%d org=%v: point at %v ended up in the other bin", div, org, x)
+			}
+			ids := make(idset)
+			db.bins[db.coordsToIndex(div-1, div-1)].traverseBin(ids.storeID)
+			ids.assertContains(t, 1)
+		}
+	}
+}
+
+func TestTinySuperBrick(t *testing.T) {
+	for _, sz := range []float64{1e-300, 5e-324} {
+		for _, div := range []int{1, 5, 1000} {
+			t.Run(fmt.Sprintf("size=%v,div=%d", sz, div), func(t *testing.T) {
+				db := NewDB[int](0, 0, sz, sz, div, div)
+				db.Attach(1, 0, 0)         // on the super-brick minimum corner
+				db.Attach(2, sz/2, sz/2)   // inside, if representable
+				db.Attach(3, sz, 0)        // just outside
+				db.Attach(4, -1, -1)       // outside
+				db.Attach(5, 1e10, -1e-10) // far away outside
+
+				if db.Len() != 5 {
+					t.Errorf("Len() = %d, want 5", db.Len())
+				}
+
+				ids := make(idset)
+				db.ForEachWithinRadius(0, 0, 0.5, ids.storeID)
+				ids.assertContains(t, 1)
+				ids.assertContains(t, 2)
+				ids.assertContains(t, 3)
+				ids.assertNotContains(t, 4)
+				ids.assertNotContains(t, 5)
+
+				ids = make(idset)
+				db.ForEachWithinRadius(sz, sz, 0.5, ids.storeID)
+				ids.assertContains(t, 1)
+				ids.assertContains(t, 2)
+				ids.assertContains(t, 3)
+
+				ids = make(idset)
+				db.ForEachWithinRadius(0, 0, 1e11, ids.storeID)
+				if len(ids) != 5 {
+					t.Errorf("query covering everything found %d objects, want 5", len(ids))
+				}
+
+				if got, found := db.FindNearestInRadius(-1, -1, 0.1, 0); !found || got != 4 {
+					t.Errorf("FindNearestInRadius() = %v, %t, want 4, true", got, found)
+				}
+				if _, found := db.FindNearestWithinBins(0, 0, 1, 0); !found {
+					t.Errorf("FindNearestWithinBins() found = false, want true")
+				}
+			})
+		}
+	}
+}
+
+func TestReattach(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 1, 1)
+	db.Attach(2, 1.2, 1.2)
+	db.Attach(3, 1.4, 1.4)
+	db.Attach(4, -1, -1)
+	db.Attach(5, -2, -2)
+
+	order := func() string {
+		var ids []int
+		db.ForEachObject(func(id int, _ float64) { ids = append(ids, id) })
+		return fmt.Sprint(ids)
+	}
+	want := order()
+
+	for cycle := 0; cycle < 3; cycle++ {
+		proxies := db.Proxies()
+		for _, p := range proxies {
+			db.Detach(p)
+		}
+		for _, p := range proxies {
+			x, y := p.Location()
+			db.Reattach(p, x, y)
+		}
+		if got := order(); got != want {
+			t.Fatalf("cycle %d: iteration order = %s, want %s", cycle, got, want)
+		}
+	}
+
+	// Reattaching an attached proxy moves it to the tail of its new bin.
+	p := db.Attach(6, 1.6, 1.6)
+	db.Reattach(p, 1.8, 1.8)
+	if got := order(); got != "[3 2 1 6 5 4]" {
+		t.Errorf("iteration order = %s, want [3 2 1 6 5 4]", got)
+	}
+	if db.Len() != 6 || db.OtherCount() != 2 {
+		t.Errorf("Len(), OtherCount() = %d, %d, want 6, 2", db.Len(), db.OtherCount())
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	active := NewDB[int](0, 0, 10, 10, 5, 5)
+	dormant := NewDB[int](100, 100, 10, 10, 5, 5)
+	p := active.Attach(1, 5, 5)
+	active.Attach(2, 6, 6)
+	sized := active.AttachSized(3, -1, -1, 4)
+
+	active.Migrate(p, dormant, 105, 105)
+	active.Migrate(sized, dormant, 50, 50)
+
+	if active.Len() != 1 || active.OtherCount() != 0 {
+		t.Errorf("source: Len(), OtherCount() = %d, %d, want 1, 0", active.Len(), active.OtherCount())
+	}
+	if dormant.Len() != 2 || dormant.OtherCount() != 1 {
+		t.Errorf("dst: Len(), OtherCount() = %d, %d, want 2, 1", dormant.Len(), dormant.OtherCount())
+	}
+	if r := active.maxRadius; r != 0 {
+		t.Errorf("source: maxRadius = %v, want 0", r)
+	}
+	if r := dormant.maxRadius; r != 4 {
+		t.Errorf("dst: maxRadius = %v, want 4", r)
+	}
+
+	ids := make(idset)
+	active.ForEachWithinRadius(5, 5, 20, ids.storeID)
+	ids.assertNotContains(t, 1)
+	ids.assertNotContains(t, 3)
+	ids.assertContains(t, 2)
+
+	ids = make(idset)
+	dormant.ForEachWithinRadius(105, 105, 1, ids.storeID)
+	ids.assertContains(t, 1)
+	if ix, iy, inOther := dormant.ProxyBin(p); ix != 2 || iy != 2 || inOther {
+		t.Errorf("ProxyBin() = %d, %d, %t, want 2, 2, false", ix, iy, inOther)
+	}
+
+	// The proxy can be updated and detached in its new database.
+	dormant.Update(p, 101, 101)
+	ids = make(idset)
+	dormant.ForEachWithinRadius(101, 101, 1, ids.storeID)
+	ids.assertContains(t, 1)
+	if !dormant.DetachReport(p) {
+		t.Errorf("DetachReport() = false, want true")
+	}
+}
+
+func TestProxyUserData(t *testing.T) {
+	type renderHandle struct{ id int }
+
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	p1 := db.Attach(1, 5, 5)
+	p2 := db.Attach(2, 5.5, 5)
+	db.Attach(3, 6, 5.5) // without user data
+	p1.SetUserData(&renderHandle{10})
+	p2.SetUserData("sprite")
+
+	got := make(map[int]any)
+	db.ForEachWithinRadiusMutate(5, 5, 2, func(p *Proxy[int], _ float64) (float64, float64, bool) {
+		got[p.Object()] = p.UserData()
+		return 0, 0, false
+	})
+	if len(got) != 3 {
+		t.Fatalf("got %d proxies, want 3", len(got))
+	}
+	if h, ok := got[1].(*renderHandle); !ok || h.id != 10 {
+		t.Errorf("object 1 user data = %v, want render handle 10", got[1])
+	}
+	if got[2] != "sprite" {
+		t.Errorf("object 2 user data = %v, want sprite", got[2])
+	}
+	if got[3] != nil {
+		t.Errorf("object 3 user data = %v, want nil", got[3])
+	}
+
+	// User data survives updates and detaching.
+	db.Update(p2, 9, 9)
+	db.Detach(p2)
+	if p2.UserData() != "sprite" {
+		t.Errorf("user data after Detach = %v, want sprite", p2.UserData())
+	}
+}
+
+func TestProxySeq(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	var proxies []*Proxy[int]
+	proxies = append(proxies, db.Attach(1, 1, 1))
+	proxies = append(proxies, db.AttachLayer(1, 1, 1, 2))
+	proxies = append(proxies, db.AttachSized(1, -1, -1, 0.5))
+	p, _ := db.AttachChecked(1, 5, 5)
+	proxies = append(proxies, p)
+	d := NewDeferred(db)
+	proxies = append(proxies, d.Attach(1, 2, 2))
+	d.Flush()
+	proxies = append(proxies, db.Attach(1, 1, 1))
+
+	for i, p := range proxies {
+		if i == 0 && p.Seq() == 0 {
+			t.Errorf("first proxy has sequence number 0")
+		}
+		if i > 0 && p.Seq() <= proxies[i-1].Seq() {
+			t.Errorf("proxy %d: Seq() = %d, not greater than previous %d", i, p.Seq(), proxies[i-1].Seq())
+		}
+	}
+
+	// Sequence numbers are kept through updates, detaches and migrations.
+	seq := proxies[0].Seq()
+	db.Update(proxies[0], 9, 9)
+	db.Detach(proxies[0])
+	db.Update(proxies[0], 1, 1)
+	db.Migrate(proxies[0], NewDB[int](0, 0, 10, 10, 5, 5), 1, 1)
+	if proxies[0].Seq() != seq {
+		t.Errorf("Seq() = %d, want %d", proxies[0].Seq(), seq)
+	}
+}
+
+func TestAttachDeferred(t *testing.T) {
+	for _, oob := range []OutOfBoundsPolicy{CatchAll, ClampToEdge, Reject} {
+		t.Run(fmt.Sprintf("policy %d", oob), func(t *testing.T) {
+			db := New[int](WithBounds(0, 0, 10, 10), WithDivisions(5, 5), WithOutOfBoundsPolicy(oob))
+			db.Attach(1, 5, 5)
+			p2 := db.AttachDeferred(2)
+			p3 := db.AttachDeferred(3)
+
+			if db.Len() != 3 {
+				t.Errorf("Len() = %d, want 3", db.Len())
+			}
+
+			// Deferred objects aren't found by spatial queries.
+			ids := make(idset)
+			db.ForEachWithinRadius(0, 0, 100, ids.storeID)
+			ids.assertContains(t, 1)
+			ids.assertNotContains(t, 2)
+			ids.assertNotContains(t, 3)
+			if got, ok := db.FindNearestInRadius(0, 0, 100, 1); ok {
+				t.Errorf("FindNearestInRadius() = %v, want nothing", got)
+			}
+
+			// But by those visiting all objects.
+			ids = make(idset)
+			db.ForEachObject(ids.storeID)
+			ids.assertContains(t, 2)
+			ids.assertContains(t, 3)
+			ids = make(idset)
+			db.ForEachOther(ids.storeID)
+			ids.assertNotContains(t, 1)
+			ids.assertContains(t, 2)
+			ids.assertContains(t, 3)
+
+			// Re-binning keeps them in the "other" bin.
+			db.Regrid(2, 2)
+			if _, _, inOther := db.ProxyBin(p2); !inOther {
+				t.Errorf("deferred object not in the other bin after Regrid")
+			}
+
+			db.SetPosition(p2, 6, 6)
+			ids = make(idset)
+			db.ForEachWithinRadius(6, 6, 1, ids.storeID)
+			ids.assertContains(t, 2)
+			ids.assertNotContains(t, 3)
+			if got, ok := db.FindNearestInRadius(6, 6, 1, 0); !ok || got != 2 {
+				t.Errorf("FindNearestInRadius() = %v, %t, want 2", got, ok)
+			}
+			if x, y := p3.Location(); !math.IsNaN(x) || !math.IsNaN(y) {
+				t.Errorf("Location() of deferred object = (%v, %v), want NaN", x, y)
+			}
+			if db.OtherCount() != 1 {
+				t.Errorf("OtherCount() = %d, want 1", db.OtherCount())
+			}
+		})
+	}
+}
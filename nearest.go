@@ -0,0 +1,700 @@
+package lq
+
+import (
+	"math"
+	"sort"
+)
+
+// binCoords returns the 2D coordinates of the bin containing the location
+// (x,y). Locations outside of the super-brick are clamped to the nearest bin
+// on the super-brick border.
+func (db *DB[T]) binCoords(x, y float64) (ix, iy int) {
+	ix = clampBin((x-db.xorg)*db.xscale, db.xdiv)
+	iy = clampBin((y-db.yorg)*db.yscale, db.ydiv)
+	return ix, iy
+}
+
+// clampBin converts a fractional bin coordinate into a bin coordinate in the
+// [0, n) range.
+func clampBin(f float64, n int) int {
+	if !(f >= 0) {
+		return 0
+	}
+	if f >= float64(n) {
+		return n - 1
+	}
+	return int(f)
+}
+
+// forEachRing visits the bins around the location (x,y), ring by ring.
+//
+// Ring 0 is the bin containing (x,y) (or the nearest bin if (x,y) is outside of
+// the super-brick), ring r is made of the bins at a Chebyshev distance of r
+// from it. Rings are clipped to the super-brick. visit is called with the head
+// of each bin of a ring, then done is called with a lower bound of the squared
+// distance from (x,y) to any bin not visited yet (+Inf if there is none).
+// Iteration stops as soon as done returns true, when all bins have been
+// visited, or after ring maxRings.
+func (db *DB[T]) forEachRing(x, y float64, maxRings int, visit func(head *Proxy[T]), done func(sqBound float64) bool) {
+	cx, cy := db.binCoords(x, y)
+	binw := db.szx / float64(db.xdiv)
+	binh := db.szy / float64(db.ydiv)
+
+	for r := 0; r <= maxRings; r++ {
+		xmin, xmax := cx-r, cx+r
+		ymin, ymax := cy-r, cy+r
+
+		// Top and bottom rows of the ring.
+		for _, j := range [2]int{ymin, ymax} {
+			if j < 0 || j >= db.ydiv {
+				continue
+			}
+			for i := xmin; i <= xmax; i++ {
+				if i >= 0 && i < db.xdiv {
+					visit(db.bins[db.coordsToIndex(i, j)])
+				}
+			}
+			if r == 0 {
+				// Both rows are the same bin.
+				break
+			}
+		}
+		// Left and right columns, corners excluded.
+		for _, i := range [2]int{xmin, xmax} {
+			if r == 0 || i < 0 || i >= db.xdiv {
+				continue
+			}
+			for j := ymin + 1; j < ymax; j++ {
+				if j >= 0 && j < db.ydiv {
+					visit(db.bins[db.coordsToIndex(i, j)])
+				}
+			}
+		}
+
+		// Compute the distance from (x,y) to the nearest side of the visited
+		// area beyond which there still are bins to visit.
+		bound := math.Inf(1)
+		if xmin > 0 {
+			bound = math.Min(bound, x-(db.xorg+float64(xmin)*binw))
+		}
+		if xmax < db.xdiv-1 {
+			bound = math.Min(bound, db.xorg+float64(xmax+1)*binw-x)
+		}
+		if ymin > 0 {
+			bound = math.Min(bound, y-(db.yorg+float64(ymin)*binh))
+		}
+		if ymax < db.ydiv-1 {
+			bound = math.Min(bound, db.yorg+float64(ymax+1)*binh-y)
+		}
+		if bound < 0 {
+			bound = 0
+		}
+		if done(bound*bound) || math.IsInf(bound, 1) {
+			return
+		}
+	}
+}
+
+// FindNearestWithinBins searches the database to find the object whose
+// key-point is nearest to a given location, looking at most maxRings bins away
+// from the bin containing that location.
+//
+// Bins are searched in rings of increasing distance from the location and the
+// search stops as soon as no object in the remaining bins could be nearer than
+// the nearest one found so far. Limiting the number of rings bounds the cost
+// of the search in sparse regions of space, where no object exists nearby.
+// Objects outside of the super-brick are only considered if the location is
+// outside of it, or if the searched rings reach its border. The ignored
+// argument can be used to exclude an object from consideration (see
+// FindNearestInRadius). As with FindNearestInRadius, ties are broken in favor
+// of the object attached first. The function returns the nearest object and
+// true, or, if there was no object within maxRings, the zero value of T and
+// false.
+func (db *DB[T]) FindNearestWithinBins(x, y float64, maxRings int, ignored T) (T, bool) {
+	nearest := *new(T)
+	minSqDist := math.MaxFloat64
+	var minSeq uint64
+	found := false
+
+	visit := func(cp *Proxy[T]) {
+		for ; cp != nil; cp = cp.next {
+			if db.isIgnored(cp.object, ignored) {
+				continue
+			}
+			sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+			if sqDist < minSqDist || (sqDist == minSqDist && cp.seq < minSeq) {
+				nearest = cp.object
+				minSqDist = sqDist
+				minSeq = cp.seq
+				found = true
+			}
+		}
+	}
+
+	rings := 0
+	db.forEachRing(x, y, maxRings, visit, func(sqBound float64) bool {
+		rings++
+		// Objects in the unvisited bins may tie with the nearest one.
+		return found && minSqDist < sqBound
+	})
+	if db.ringsReachOther(x, y, rings-1) {
+		visit(db.other)
+	}
+
+	return nearest, found
+}
+
+// ringsReachOther reports whether the objects of the "other" bin may be within
+// the rings 0 to r around (x,y), as visited by forEachRing.
+//
+// That's the case if (x,y) is outside of the super-brick, or if ring r reaches
+// its border. Otherwise, the distance from (x,y) to any object outside of the
+// super-brick is greater than the distance to the bins not visited yet, which
+// forEachRing passes to done.
+func (db *DB[T]) ringsReachOther(x, y float64, r int) bool {
+	if db.alwaysOther || db.binIndex(x, y) < 0 {
+		return true
+	}
+	cx, cy := db.binCoords(x, y)
+	return cx-r <= 0 || cy-r <= 0 || cx+r >= db.xdiv-1 || cy+r >= db.ydiv-1
+}
+
+// FindNearestBudgeted searches the database to find the object nearest to a
+// given location yet within a given radius, examining at most maxBins bins.
+//
+// Like FindNearestWithinBins, bins are searched in rings of increasing distance
+// from the location, the "other" bin first if the search circle extends
+// outside of the super-brick. The search gives up once maxBins bins have been
+// examined, which caps its cost in sparse areas for applications with strict
+// time budgets. It returns the nearest object found and true, or the zero value
+// of T and false if none was found. complete reports whether the search could
+// prove that the object found (or the absence of object) is the actual result
+// of FindNearestInRadius, which is false if the budget has been exhausted
+// before.
+func (db *DB[T]) FindNearestBudgeted(x, y, radius float64, maxBins int, ignored T) (nearest T, found, complete bool) {
+	sqRadius := radius * radius
+	minSqDist := sqRadius
+	var minSeq uint64
+	bins, exhausted := 0, false
+
+	visit := func(cp *Proxy[T]) {
+		if bins >= maxBins {
+			exhausted = true
+			return
+		}
+		bins++
+		for ; cp != nil; cp = cp.next {
+			sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+			if sqDist < minSqDist || (found && sqDist == minSqDist && cp.seq < minSeq) {
+				if !db.isIgnored(cp.object, ignored) {
+					nearest = cp.object
+					minSqDist = sqDist
+					minSeq = cp.seq
+					found = true
+				}
+			}
+		}
+	}
+
+	if _, _, _, _, outside := db.binRange(x-radius, y-radius, x+radius, y+radius); outside {
+		visit(db.other)
+	}
+	maxRings := db.xdiv
+	if db.ydiv > maxRings {
+		maxRings = db.ydiv
+	}
+	complete = true
+	db.forEachRing(x, y, maxRings, visit, func(sqBound float64) bool {
+		if exhausted {
+			complete = false
+			return true
+		}
+		// Objects in the unvisited bins may tie with the nearest one.
+		return sqBound >= sqRadius || (found && minSqDist < sqBound)
+	})
+	return nearest, found, complete
+}
+
+// NearestDistanceField samples the distance to the nearest object at the
+// center of each bin.
+//
+// The returned field has xdiv rows of ydiv values, field[ix][iy] being the
+// distance from the center of bin (ix,iy) to the nearest object within radius,
+// or +Inf if there's none. This is useful for potential-field navigation for
+// example. Rather than running one search per bin center, each object is
+// visited once and updates the bin centers within radius of it, so the cost
+// is proportional to the number of bins plus the number of objects times the
+// number of bin centers within radius of an object. radius should thus be kept
+// small relative to the super-brick.
+func (db *DB[T]) NearestDistanceField(radius float64) [][]float64 {
+	binw := db.szx / float64(db.xdiv)
+	binh := db.szy / float64(db.ydiv)
+
+	sqDists := make([]float64, db.xdiv*db.ydiv)
+	for i := range sqDists {
+		sqDists[i] = math.Inf(1)
+	}
+
+	sqRadius := radius * radius
+	scatter := func(cp *Proxy[T]) {
+		if cp.x != cp.x || cp.y != cp.y {
+			return // no location yet, see AttachDeferred
+		}
+		// Range of bins whose center may be within radius, rounded outwards:
+		// the distances are checked anyway.
+		ix0 := clampBin(math.Floor((cp.x-radius-db.xorg)/binw-0.5), db.xdiv)
+		ix1 := clampBin(math.Ceil((cp.x+radius-db.xorg)/binw-0.5), db.xdiv)
+		iy0 := clampBin(math.Floor((cp.y-radius-db.yorg)/binh-0.5), db.ydiv)
+		iy1 := clampBin(math.Ceil((cp.y+radius-db.yorg)/binh-0.5), db.ydiv)
+		for ix := ix0; ix <= ix1; ix++ {
+			x := db.xorg + (float64(ix)+0.5)*binw
+			for iy := iy0; iy <= iy1; iy++ {
+				y := db.yorg + (float64(iy)+0.5)*binh
+				sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+				if i := ix*db.ydiv + iy; sqDist < sqRadius && sqDist < sqDists[i] {
+					sqDists[i] = sqDist
+				}
+			}
+		}
+	}
+	for _, head := range db.bins {
+		for cp := head; cp != nil; cp = cp.next {
+			scatter(cp)
+		}
+	}
+	for cp := db.other; cp != nil; cp = cp.next {
+		scatter(cp)
+	}
+
+	field := make([][]float64, db.xdiv)
+	for ix := range field {
+		field[ix] = sqDists[ix*db.ydiv : (ix+1)*db.ydiv]
+		for iy, sqDist := range field[ix] {
+			field[ix][iy] = math.Sqrt(sqDist)
+		}
+	}
+	return field
+}
+
+// Neighbor is an object found by a nearest neighbors search, along with its
+// squared distance to the search location.
+type Neighbor[T any] struct {
+	Object T
+	SqDist float64
+}
+
+// nearer orders neighbors by increasing distance.
+func nearer[T any](a, b Neighbor[T]) bool {
+	return a.SqDist < b.SqDist
+}
+
+// FindKNearestReport searches the database to find the k objects whose
+// key-points are nearest to a given location yet within a given radius.
+//
+// The neighbors are returned sorted by increasing distance, there are fewer
+// than k of them if the search circle contains less than k objects. The
+// ignored argument can be used to exclude an object from consideration (see
+// FindNearestInRadius). moreAvailable reports whether the search circle
+// contained more than k objects, which can be used to adapt the radius of
+// later searches.
+func (db *DB[T]) FindKNearestReport(x, y, radius float64, k int, ignored T) (neighbors []Neighbor[T], moreAvailable bool) {
+	h := newBoundedHeap(k, nearer[T])
+	n := 0
+	db.ForEachWithinRadius(x, y, radius, func(obj T, sqDist float64) {
+		if db.isIgnored(obj, ignored) {
+			return
+		}
+		n++
+		h.push(Neighbor[T]{Object: obj, SqDist: sqDist})
+	})
+	return h.sorted(), n > k
+}
+
+// FindKNearestInclusiveTies searches the database to find the k objects whose
+// key-points are nearest to a given location, plus those tied with the k-th
+// nearest one.
+//
+// Instead of arbitrarily truncating the result when several objects are at the
+// same distance as the k-th nearest one, all of them are returned, so there
+// can be more than k neighbors. There are fewer than k of them if the database
+// contains less than k objects. The neighbors are sorted by increasing
+// distance. Bins are searched in rings of increasing distance from the
+// location, as with FindNearestWithinBins, objects outside of the super-brick
+// being considered when the rings reach its border. The ignored argument can
+// be used to exclude an object from consideration (see FindNearestInRadius).
+func (db *DB[T]) FindKNearestInclusiveTies(x, y float64, k int, ignored T) []Neighbor[T] {
+	if k <= 0 {
+		return nil
+	}
+
+	h := newBoundedHeap(k, nearer[T])
+	// Objects tied with the k-th nearest one, which didn't fit in the heap.
+	var ties []Neighbor[T]
+	visit := func(cp *Proxy[T]) {
+		for ; cp != nil; cp = cp.next {
+			if db.isIgnored(cp.object, ignored) {
+				continue
+			}
+			nb := Neighbor[T]{Object: cp.object, SqDist: (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)}
+			if !h.full() {
+				h.push(nb)
+				continue
+			}
+			switch kth := h.max(); {
+			case nb.SqDist == kth.SqDist:
+				ties = append(ties, nb)
+			case nb.SqDist < kth.SqDist:
+				h.push(nb)
+				if h.max().SqDist == kth.SqDist {
+					ties = append(ties, kth)
+				} else {
+					ties = ties[:0]
+				}
+			}
+		}
+	}
+
+	maxRings := db.xdiv
+	if db.ydiv > maxRings {
+		maxRings = db.ydiv
+	}
+	rings := 0
+	db.forEachRing(x, y, maxRings, visit, func(sqBound float64) bool {
+		rings++
+		// Objects in the unvisited bins may tie with the k-th nearest one.
+		return h.full() && h.max().SqDist < sqBound
+	})
+	if db.ringsReachOther(x, y, rings-1) {
+		visit(db.other)
+	}
+
+	return append(h.sorted(), ties...)
+}
+
+// FindAllNearestInRadius is like FindNearestInRadius but it returns all the
+// objects tied for nearest.
+//
+// An object is tied for nearest if its squared distance to (x,y) is within eps
+// of the smallest squared distance found, eps = 0 only returning exact ties.
+// The objects are sorted by increasing distance, objects at the same distance
+// being in the order in which the query visits them. nil is returned if there
+// is no object within radius.
+func (db *DB[T]) FindAllNearestInRadius(x, y, radius float64, ignored T, eps float64) []T {
+	if eps < 0 {
+		eps = 0
+	}
+
+	var cands []Neighbor[T]
+	minSqDist := math.MaxFloat64
+	db.ForEachWithinRadius(x, y, radius, func(obj T, sqDist float64) {
+		if db.isIgnored(obj, ignored) || sqDist > minSqDist+eps {
+			return
+		}
+		if sqDist < minSqDist {
+			minSqDist = sqDist
+			// Prune the candidates which aren't tied anymore.
+			n := 0
+			for _, c := range cands {
+				if c.SqDist <= minSqDist+eps {
+					cands[n] = c
+					n++
+				}
+			}
+			cands = cands[:n]
+		}
+		cands = append(cands, Neighbor[T]{Object: obj, SqDist: sqDist})
+	})
+	if len(cands) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].SqDist < cands[j].SqDist })
+	objs := make([]T, len(cands))
+	for i, c := range cands {
+		objs[i] = c.Object
+	}
+	return objs
+}
+
+// Sector is the result of a nearest neighbor search in an angular sector, see
+// NearestPerSector.
+type Sector[T any] struct {
+	Neighbor[T]
+	Found bool // whether an object has been found in the sector
+}
+
+// NearestPerSector searches the database to find, in each of several angular
+// sectors around a given location, the object nearest to that location yet
+// within a given radius.
+//
+// The circle of the given radius centered on (x,y) is split into sectors
+// equal sectors. Sector i covers the bearings from i*2π/sectors (included) to
+// (i+1)*2π/sectors (excluded), bearings being measured counterclockwise from
+// the positive x axis. An object at (x,y) belongs to sector 0. The returned
+// slice holds the result of each sector, Found is false for sectors in which
+// no object has been found. All sectors are searched at once, which is useful
+// to implement "whisker" sensors for example. NearestPerSector returns nil if
+// sectors isn't positive.
+func (db *DB[T]) NearestPerSector(x, y, radius float64, sectors int) []Sector[T] {
+	if sectors <= 0 {
+		return nil
+	}
+
+	res := make([]Sector[T], sectors)
+	width := 2 * math.Pi / float64(sectors)
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		dx, dy := cp.x-x, cp.y-y
+		sqDist := dx*dx + dy*dy
+		if sqDist >= sqRadius {
+			return
+		}
+
+		bearing := math.Atan2(dy, dx)
+		if bearing < 0 {
+			bearing += 2 * math.Pi
+		}
+		i := int(bearing / width)
+		if i >= sectors {
+			// Bearings rounded up to 2π.
+			i = 0
+		}
+		if sec := &res[i]; !sec.Found || sqDist < sec.SqDist {
+			sec.Object = cp.object
+			sec.SqDist = sqDist
+			sec.Found = true
+		}
+	})
+	return res
+}
+
+// FindTwoNearestInRadius searches the database to find the two objects whose
+// key-points are nearest to a given location yet within a given radius.
+//
+// It's like FindNearestInRadius, but both objects are found in a single
+// search, first being the nearest one and second the next nearest one.
+// foundFirst and foundSecond report whether each object has been found: if
+// there is a single object within radius (the ignored one aside), only
+// foundFirst is true.
+func (db *DB[T]) FindTwoNearestInRadius(x, y, radius float64, ignored T) (first, second T, foundFirst, foundSecond bool) {
+	sqDist1, sqDist2 := math.MaxFloat64, math.MaxFloat64
+	db.ForEachWithinRadius(x, y, radius, func(obj T, sqDist float64) {
+		if db.isIgnored(obj, ignored) {
+			return
+		}
+		switch {
+		case sqDist < sqDist1:
+			second, sqDist2, foundSecond = first, sqDist1, foundFirst
+			first, sqDist1, foundFirst = obj, sqDist, true
+		case sqDist < sqDist2:
+			second, sqDist2, foundSecond = obj, sqDist, true
+		}
+	})
+	return first, second, foundFirst, foundSecond
+}
+
+// FindNearestVisible searches the database to find the object whose key-point
+// is nearest to a given location yet within a given radius, and visible from
+// that location.
+//
+// visible is called with the search location (x0,y0) and the location of a
+// candidate object (x1,y1), it reports whether there is a line of sight
+// between them, for example by testing the segment against walls. Objects
+// which aren't visible are skipped. visible is only called for objects closer
+// than the nearest visible object found so far, but it's still typically much
+// more expensive than the search itself. The function returns the nearest
+// visible object and true, or the zero value of T and false if there is none.
+func (db *DB[T]) FindNearestVisible(x, y, radius float64, visible func(x0, y0, x1, y1 float64) bool) (T, bool) {
+	nearest := *new(T)
+	minSqDist := radius * radius
+	found := false
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if sqDist < minSqDist && visible(x, y, cp.x, cp.y) {
+			nearest = cp.object
+			minSqDist = sqDist
+			found = true
+		}
+	})
+	return nearest, found
+}
+
+// NearestStatus describes the outcome of a nearest neighbor search.
+type NearestStatus int
+
+const (
+	// FoundMatch means that the nearest object has been found.
+	FoundMatch NearestStatus = iota
+	// NoneInRange means that the database contains objects, but none of them
+	// is within the search radius (the ignored object aside).
+	NoneInRange
+	// DatabaseEmpty means that the database doesn't contain any object.
+	DatabaseEmpty
+)
+
+// FindNearestInRadiusStatus is like FindNearestInRadius but it returns a
+// status telling apart the reasons why no object may have been found.
+//
+// This helps deciding between widening the search radius (NoneInRange) and
+// giving up (DatabaseEmpty).
+func (db *DB[T]) FindNearestInRadiusStatus(x, y, radius float64, ignored T) (T, NearestStatus) {
+	if db.n == 0 {
+		return *new(T), DatabaseEmpty
+	}
+	nearest, found := db.FindNearestInRadius(x, y, radius, ignored)
+	if !found {
+		return nearest, NoneInRange
+	}
+	return nearest, FoundMatch
+}
+
+// FindAtLeastN searches the database for the smallest radius, around a given
+// location, containing at least n objects.
+//
+// The search starts with a circle of radius startRadius, whose radius is then
+// multiplied by growth until the circle contains at least n objects or its
+// radius reaches maxRadius. FindAtLeastN returns the objects within the last
+// circle, in no particular order, its radius and whether it contains at least
+// n objects. Each growth step only visits the bins that weren't overlapped by
+// the previous circle. FindAtLeastN panics if startRadius isn't positive or if
+// growth isn't greater than 1.
+func (db *DB[T]) FindAtLeastN(x, y float64, n int, startRadius, maxRadius, growth float64) ([]T, float64, bool) {
+	if !(startRadius > 0) {
+		panic("lq: FindAtLeastN startRadius must be positive")
+	}
+	if !(growth > 1) {
+		panic("lq: FindAtLeastN growth must be greater than 1")
+	}
+
+	// Objects in the bins visited so far, along with their squared distance to
+	// (x,y). Visited bins are [vx0,vx1]×[vy0,vy1], which is initially empty.
+	var cands []Neighbor[T]
+	vx0, vy0, vx1, vy1 := 0, 0, -1, -1
+	otherVisited := false
+	collect := func(cp *Proxy[T]) {
+		for ; cp != nil; cp = cp.next {
+			sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+			cands = append(cands, Neighbor[T]{Object: cp.object, SqDist: sqDist})
+		}
+	}
+
+	r := startRadius
+	if r > maxRadius {
+		r = maxRadius
+	}
+	for {
+		minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-r, y-r, x+r, y+r)
+		if outside && !otherVisited {
+			collect(db.other)
+			otherVisited = true
+		}
+		for i := minBinX; i <= maxBinX; i++ {
+			for j := minBinY; j <= maxBinY; j++ {
+				if i >= vx0 && i <= vx1 && j >= vy0 && j <= vy1 {
+					continue // already visited
+				}
+				collect(db.bins[db.coordsToIndex(i, j)])
+			}
+		}
+		vx0, vy0, vx1, vy1 = minBinX, minBinY, maxBinX, maxBinY
+
+		found := 0
+		sqRadius := r * r
+		for i := range cands {
+			if cands[i].SqDist < sqRadius {
+				found++
+			}
+		}
+		if found >= n || r >= maxRadius {
+			objs := make([]T, 0, found)
+			for i := range cands {
+				if cands[i].SqDist < sqRadius {
+					objs = append(objs, cands[i].Object)
+				}
+			}
+			return objs, r, found >= n
+		}
+
+		r *= growth
+		if r > maxRadius {
+			r = maxRadius
+		}
+	}
+}
+
+// NearestAmong returns the proxy, among candidates, whose stored location is
+// nearest to (x,y), and true, or nil and false if there are no candidates.
+//
+// Distances are computed from the locations given during the last call to
+// Attach or Update, the database isn't searched. Among candidates at the same
+// distance, the one attached first wins.
+func (db *DB[T]) NearestAmong(x, y float64, candidates []*Proxy[T]) (*Proxy[T], bool) {
+	var nearest *Proxy[T]
+	minSqDist := math.MaxFloat64
+	for _, cp := range candidates {
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if nearest == nil || sqDist < minSqDist || (sqDist == minSqDist && cp.seq < nearest.seq) {
+			nearest = cp
+			minSqDist = sqDist
+		}
+	}
+	return nearest, nearest != nil
+}
+
+// findNearestProxyInRadius is like FindNearestInRadius but returns the proxy of
+// the nearest object, or nil. It doesn't support frozen databases.
+func (db *DB[T]) findNearestProxyInRadius(x, y, radius float64, ignored T) *Proxy[T] {
+	var nearest *Proxy[T]
+	minSqDist := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if sqDist < minSqDist || (nearest != nil && sqDist == minSqDist && cp.seq < nearest.seq) {
+			if !db.isIgnored(cp.object, ignored) {
+				nearest = cp
+				minSqDist = sqDist
+			}
+		}
+	})
+	return nearest
+}
+
+// FindNearestOfLayer is like FindNearestInRadius but only considers the
+// objects belonging to at least one of the layers in mask (see AttachLayer).
+//
+// This is the "nearest enemy" query: objects of other layers are skipped, even
+// if they are nearer. As with FindNearestInRadius, ties are broken in favor of
+// the object attached first.
+func (db *DB[T]) FindNearestOfLayer(x, y, radius float64, mask uint32, ignored T) (T, bool) {
+	var nearest *Proxy[T]
+	minSqDist := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		if cp.layer&mask == 0 {
+			return
+		}
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if sqDist < minSqDist || (nearest != nil && sqDist == minSqDist && cp.seq < nearest.seq) {
+			if !db.isIgnored(cp.object, ignored) {
+				nearest = cp
+				minSqDist = sqDist
+			}
+		}
+	})
+	if nearest == nil {
+		return *new(T), false
+	}
+	return nearest.object, true
+}
+
+// PopNearestInRadius finds the object nearest to a given location yet within a
+// given radius, like FindNearestInRadius, detaches it from the database and
+// returns it, and true. If there is no object within radius, it returns the
+// zero value of T, and false.
+func (db *DB[T]) PopNearestInRadius(x, y, radius float64, ignored T) (T, bool) {
+	db.mustNotBeFrozen("PopNearestInRadius")
+	cp := db.findNearestProxyInRadius(x, y, radius, ignored)
+	if cp == nil {
+		return *new(T), false
+	}
+	db.Detach(cp)
+	return cp.object, true
+}
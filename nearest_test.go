@@ -0,0 +1,770 @@
+package lq
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestFindNearestWithinBins(t *testing.T) {
+	var tests = []struct {
+		px, py    float64 // position of the single object in the db
+		cx, cy    float64 // search location
+		maxRings  int
+		wantFound bool
+	}{
+		{0.5, 0.5, 0.5, 0.5, 0, true},
+		{1.5, 0.5, 0.5, 0.5, 0, false},
+		{1.5, 0.5, 0.5, 0.5, 1, true},
+		{9.5, 9.5, 0.5, 0.5, 3, false},
+		{9.5, 9.5, 0.5, 0.5, 8, false},
+		{9.5, 9.5, 0.5, 0.5, 9, true},
+		{9.5, 9.5, 0.5, 0.5, 100, true},
+		{-5, -5, 0.5, 0.5, 0, true},    // outside of the super-brick
+		{9.5, 9.5, 20, 20, 0, true},    // search location outside of the super-brick
+		{0.5, 9.5, -20, -20, 8, false}, // search location outside of the super-brick
+		{0.5, 9.5, -20, -20, 9, true},  // search location outside of the super-brick
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("nearest within bins %d", i), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 10, 10)
+			db.Attach(1, tt.px, tt.py)
+
+			got, found := db.FindNearestWithinBins(tt.cx, tt.cy, tt.maxRings, 0)
+			if found != tt.wantFound {
+				t.Fatalf("found = %t, wantFound = %t", found, tt.wantFound)
+			}
+			if found && got != 1 {
+				t.Errorf("got nearest neighbour = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestFindNearestWithinBinsPruning(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 10, 10)
+
+	// Object 2 is in a farther ring than object 1 but is nearer the search
+	// location, so the search must not stop right after finding object 1.
+	db.Attach(1, 4.95, 5.5)
+	db.Attach(2, 3.9, 5.5)
+	db.Attach(3, 9.5, 9.5)
+
+	got, found := db.FindNearestWithinBins(4.1, 5.5, 10, 0)
+	if !found {
+		t.Fatalf("found = false, want true")
+	}
+	if got != 2 {
+		t.Errorf("got nearest neighbour = %v, want 2", got)
+	}
+}
+
+func TestFindNearestWithinBinsOther(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 10, 10)
+	for i := 0; i < 1000; i++ {
+		db.Attach(-1-i, -1, float64(i%10)) // outside of the super-brick
+	}
+	db.Attach(1, 5.5, 4.5)
+
+	// Count the objects the search looks at.
+	visited := 0
+	db.SetEquals(func(a, b int) bool {
+		visited++
+		return a == b
+	})
+
+	var tests = []struct {
+		x, y     float64
+		maxRings int
+		want     int
+		visited  int // max number of objects visited
+	}{
+		{5.5, 5.5, 3, 1, 1},      // rings don't reach the border
+		{5.5, 5.5, 4, 1, 1},      // object found before reaching the border
+		{0.5, 5.5, 0, -1, 1000},  // ring 0 is on the border
+		{-0.5, 5.5, 0, -1, 1000}, // location outside of the super-brick
+		{3.5, 9.5, 2, -1, 1000},  // rings reach the top border
+		{1.5, 5.5, 0, 0, 0},      // nothing found in ring 0
+		{1.5, 4.5, 5, -1, 1001},  // objects outside are nearer than 1
+	}
+	for _, tt := range tests {
+		visited = 0
+		got, _ := db.FindNearestWithinBins(tt.x, tt.y, tt.maxRings, 0)
+		if tt.want < 0 {
+			if got >= 0 {
+				t.Errorf("(%v, %v, %d): got %d, want an object outside of the super-brick", tt.x, tt.y, tt.maxRings, got)
+			}
+		} else if got != tt.want {
+			t.Errorf("(%v, %v, %d): got %d, want %d", tt.x, tt.y, tt.maxRings, got, tt.want)
+		}
+		if visited > tt.visited {
+			t.Errorf("(%v, %v, %d): visited %d objects, want at most %d", tt.x, tt.y, tt.maxRings, visited, tt.visited)
+		}
+	}
+}
+
+func TestSetEquals(t *testing.T) {
+	type entity struct {
+		ID   int // 0 for anonymous entities
+		Name string
+	}
+	byID := func(a, b entity) bool { return a.ID != 0 && a.ID == b.ID }
+
+	t.Run("equal by ID", func(t *testing.T) {
+		db := NewDB[entity](0, 0, 10, 10, 5, 5)
+		db.Attach(entity{1, "renamed"}, 5, 5)
+		db.Attach(entity{2, "other"}, 6, 6)
+
+		// With ==, the ignored entity isn't excluded since its name changed.
+		ignored := entity{1, "original"}
+		if got, _ := db.FindNearestInRadius(5, 5, 3, ignored); got.ID != 1 {
+			t.Errorf("with ==, got nearest neighbour = %v, want ID 1", got)
+		}
+
+		db.SetEquals(byID)
+		if got, _ := db.FindNearestInRadius(5, 5, 3, ignored); got.ID != 2 {
+			t.Errorf("with custom equality, got nearest neighbour = %v, want ID 2", got)
+		}
+		if got, _ := db.FindNearestWithinBins(5, 5, 1, ignored); got.ID != 2 {
+			t.Errorf("with custom equality, got nearest neighbour within bins = %v, want ID 2", got)
+		}
+
+		db.SetEquals(nil)
+		if got, _ := db.FindNearestInRadius(5, 5, 3, ignored); got.ID != 1 {
+			t.Errorf("after reset, got nearest neighbour = %v, want ID 1", got)
+		}
+	})
+
+	t.Run("anonymous entities", func(t *testing.T) {
+		db := NewDB[entity](0, 0, 10, 10, 5, 5)
+		db.Attach(entity{0, "rock"}, 5, 5)
+
+		// With ==, all anonymous rocks are equal and thus excluded.
+		ignored := entity{0, "rock"}
+		if _, found := db.FindNearestInRadius(5, 5, 3, ignored); found {
+			t.Errorf("with ==, found = true, want false")
+		}
+
+		db.SetEquals(byID)
+		if got, found := db.FindNearestInRadius(5, 5, 3, ignored); !found || got != ignored {
+			t.Errorf("with custom equality, got nearest neighbour = %v, %t, want %v, true", got, found, ignored)
+		}
+	})
+}
+
+func TestNearestDistanceField(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 1, 1)
+	db.Attach(2, 4, 1)
+	db.Attach(3, 9, 9)
+	db.Attach(4, 11, 3) // outside of the super-brick
+
+	field := db.NearestDistanceField(2.5)
+	if len(field) != 5 {
+		t.Fatalf("len(field) = %d, want 5", len(field))
+	}
+
+	inf := math.Inf(1)
+	want := [5][5]float64{
+		{0, 2, inf, inf, inf},
+		{1, math.Sqrt(5), inf, inf, inf},
+		{1, math.Sqrt(5), inf, inf, inf},
+		{inf, inf, inf, inf, 2},
+		{inf, 2, inf, 2, 0},
+	}
+	for ix := range want {
+		if len(field[ix]) != 5 {
+			t.Fatalf("len(field[%d]) = %d, want 5", ix, len(field[ix]))
+		}
+		for iy := range want[ix] {
+			if field[ix][iy] != want[ix][iy] {
+				t.Errorf("field[%d][%d] = %v, want %v", ix, iy, field[ix][iy], want[ix][iy])
+			}
+		}
+	}
+}
+
+func TestNearestDistanceFieldRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	db := NewDB[int](-3, 2, 17, 9, 7, 4)
+	for i := 0; i < 200; i++ {
+		db.Attach(i, -5+rng.Float64()*22, rng.Float64()*13)
+	}
+	db.AttachDeferred(200)
+
+	binw, binh := 17.0/7, 9.0/4
+	for _, radius := range []float64{0.5, 2, 30} {
+		field := db.NearestDistanceField(radius)
+		for ix := range field {
+			for iy := range field[ix] {
+				x, y := -3+(float64(ix)+0.5)*binw, 2+(float64(iy)+0.5)*binh
+				want := math.Inf(1)
+				db.ForEachWithinRadius(x, y, radius, func(_ int, sqDist float64) {
+					want = math.Min(want, sqDist)
+				})
+				if want = math.Sqrt(want); field[ix][iy] != want {
+					t.Errorf("radius %v: field[%d][%d] = %v, want %v", radius, ix, iy, field[ix][iy], want)
+				}
+			}
+		}
+	}
+}
+
+func TestFindKNearestInclusiveTies(t *testing.T) {
+	var tests = []struct {
+		k    int
+		want []int
+	}{
+		{0, nil},
+		{1, []int{2}},
+		{2, []int{2, 3, 4, 5, 6}},
+		{3, []int{2, 3, 4, 5, 6}},
+		{5, []int{2, 3, 4, 5, 6}},
+		{6, []int{2, 3, 4, 5, 6, 8}},
+		{10, []int{2, 3, 4, 5, 6, 7, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("k=%d", tt.k), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 5, 5) // ignored
+			db.Attach(2, 6, 5)
+			// Tied at distance 2, in different bins.
+			db.Attach(3, 7, 5)
+			db.Attach(4, 5, 7)
+			db.Attach(5, 3, 5)
+			db.Attach(6, 5, 3)
+			db.Attach(7, -1, 5) // in the other bin
+			db.Attach(8, 9, 9)
+
+			neighbors := db.FindKNearestInclusiveTies(5, 5, tt.k, 1)
+			got := make(idset)
+			for i, n := range neighbors {
+				got.storeID(n.Object, n.SqDist)
+				if i > 0 && n.SqDist < neighbors[i-1].SqDist {
+					t.Errorf("neighbors not sorted by distance: %v", neighbors)
+				}
+			}
+			if len(neighbors) != len(tt.want) {
+				t.Errorf("got %d neighbors %v, want %v", len(neighbors), neighbors, tt.want)
+			}
+			for _, id := range tt.want {
+				got.assertContains(t, id)
+			}
+		})
+	}
+}
+
+func TestFindKNearestReport(t *testing.T) {
+	var tests = []struct {
+		k        int
+		radius   float64
+		want     []int
+		wantMore bool
+	}{
+		{0, 3, []int{}, true},
+		{2, 3, []int{2, 3}, true},
+		{3, 3, []int{2, 3, 4}, false},
+		{4, 3, []int{2, 3, 4}, false},
+		{3, 2.5, []int{2, 3}, false},
+		{1, 0.1, []int{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("k=%d,radius=%v", tt.k, tt.radius), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 5, 5) // ignored
+			db.Attach(2, 6, 5)
+			db.Attach(3, 5, 7)
+			db.Attach(4, 2.5, 6.5)
+			db.Attach(5, 9, 9)
+
+			neighbors, more := db.FindKNearestReport(5, 5, tt.radius, tt.k, 1)
+			if more != tt.wantMore {
+				t.Errorf("moreAvailable = %t, want %t", more, tt.wantMore)
+			}
+			got := make([]int, len(neighbors))
+			for i, n := range neighbors {
+				got[i] = n.Object
+				if i > 0 && n.SqDist < neighbors[i-1].SqDist {
+					t.Errorf("neighbors not sorted by distance: %v", neighbors)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got neighbors %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindNearestInRadiusStatus(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+
+	if _, status := db.FindNearestInRadiusStatus(5, 5, 1, 0); status != DatabaseEmpty {
+		t.Errorf("empty database: status = %v, want DatabaseEmpty", status)
+	}
+
+	p1 := db.Attach(1, 5, 5)
+	db.Attach(2, 9, 9)
+	if got, status := db.FindNearestInRadiusStatus(5, 5, 1, 0); status != FoundMatch || got != 1 {
+		t.Errorf("got %v, status = %v, want 1, FoundMatch", got, status)
+	}
+	if _, status := db.FindNearestInRadiusStatus(1, 1, 1, 0); status != NoneInRange {
+		t.Errorf("status = %v, want NoneInRange", status)
+	}
+	if _, status := db.FindNearestInRadiusStatus(5, 5, 1, 1); status != NoneInRange {
+		t.Errorf("only the ignored object in range: status = %v, want NoneInRange", status)
+	}
+
+	db.DetachAll()
+	if _, status := db.FindNearestInRadiusStatus(5, 5, 1, 0); status != DatabaseEmpty {
+		t.Errorf("after DetachAll: status = %v, want DatabaseEmpty", status)
+	}
+	db.Update(p1, 5, 5)
+	if _, status := db.FindNearestInRadiusStatus(5, 5, 1, 0); status != FoundMatch {
+		t.Errorf("after re-attaching: status = %v, want FoundMatch", status)
+	}
+}
+
+func TestFindAtLeastN(t *testing.T) {
+	var tests = []struct {
+		n          int
+		start, max float64
+		growth     float64
+		want       []int
+		wantRadius float64
+		wantOK     bool
+	}{
+		{1, 0.5, 10, 2, []int{1}, 0.5, true},
+		{2, 0.5, 10, 2, []int{1, 2}, 2, true},
+		{3, 0.5, 10, 2, []int{1, 2, 3}, 4, true},
+		{4, 0.5, 10, 2, []int{1, 2, 3, 4}, 8, true},
+		{5, 0.5, 10, 2, []int{1, 2, 3, 4}, 10, false},
+		{5, 0.5, 20, 2, []int{1, 2, 3, 4, 5}, 16, true},
+		{2, 0.5, 10, 3, []int{1, 2, 3}, 4.5, true},
+		{3, 0.5, 3, 2, []int{1, 2}, 3, false},
+		{1, 20, 10, 2, []int{1, 2, 3, 4}, 10, true},
+		{0, 0.1, 10, 2, []int{}, 0.1, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("n=%d,start=%v,max=%v,growth=%v", tt.n, tt.start, tt.max, tt.growth), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 10, 10)
+			db.Attach(1, 5.2, 5)
+			db.Attach(2, 6.5, 5)
+			db.Attach(3, 5, 8.5)
+			db.Attach(4, 0.5, 0.5)
+			db.Attach(5, -5, 15) // outside of the super-brick
+
+			objs, radius, ok := db.FindAtLeastN(5, 5, tt.n, tt.start, tt.max, tt.growth)
+			if radius != tt.wantRadius || ok != tt.wantOK {
+				t.Errorf("radius, ok = %v, %t, want %v, %t", radius, ok, tt.wantRadius, tt.wantOK)
+			}
+			got := make(idset)
+			for _, id := range objs {
+				got.storeID(id, 0)
+			}
+			if len(objs) != len(tt.want) {
+				t.Errorf("got objects %v, want %v", objs, tt.want)
+			}
+			for _, id := range tt.want {
+				got.assertContains(t, id)
+			}
+		})
+	}
+}
+
+func TestFindAtLeastNPanics(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 10, 10)
+	for _, args := range [][2]float64{{1, 1}, {1, 0.5}, {0, 2}, {-1, 2}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("FindAtLeastN(startRadius=%v, growth=%v) didn't panic", args[0], args[1])
+				}
+			}()
+			db.FindAtLeastN(5, 5, 1, args[0], 10, args[1])
+		}()
+	}
+}
+
+func TestFindAllNearestInRadius(t *testing.T) {
+	var tests = []struct {
+		radius float64
+		eps    float64
+		want   []int
+	}{
+		{3, 0, []int{2, 3}},
+		{3, 0.3, []int{2, 3}},
+		{3, 0.5, []int{2, 3, 4}},
+		{3, -1, []int{2, 3}},
+		{1, 0, nil},
+		{10, 100, []int{2, 3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("radius=%v,eps=%v", tt.radius, tt.eps), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 5, 5)    // ignored
+			db.Attach(5, 8, 5)    // not tied
+			db.Attach(2, 6.5, 5)  // tied with 3
+			db.Attach(3, 5, 3.5)  // tied with 2
+			db.Attach(4, 3.4, 5)  // sqDist 2.56, nearly tied
+			db.Attach(6, -2.5, 5) // outside of the super-brick
+
+			got := db.FindAllNearestInRadius(5, 5, tt.radius, 1, tt.eps)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("got %v, want nil", got)
+				}
+				return
+			}
+			ids := make(idset)
+			for _, id := range got {
+				ids.storeID(id, 0)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			for _, id := range tt.want {
+				ids.assertContains(t, id)
+			}
+		})
+	}
+}
+
+func TestNearestPerSector(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 6, 5)      // east, sector 0
+	db.Attach(2, 7, 5.5)    // east, sector 0, farther
+	db.Attach(3, 6, 6)      // north-east, sector 1
+	db.Attach(4, 5, 7)      // north, sector 2
+	db.Attach(5, 4, 4)      // south-west, sector 5
+	db.Attach(6, 5.5, 3.5)  // south-southeast, sector 6
+	db.Attach(7, 5, 4.9)    // south, on the border of sectors 5 and 6
+	db.Attach(8, 9, 9)      // out of radius
+	db.Attach(9, 5, 5)      // at the center, sector 0
+	db.Attach(10, 2.5, 5.1) // west, sector 3
+
+	got := db.NearestPerSector(5, 5, 3, 8)
+	want := []struct {
+		found bool
+		id    int
+	}{
+		{true, 9},
+		{true, 3},
+		{true, 4},
+		{true, 10},
+		{false, 0},
+		{true, 5},
+		{true, 7},
+		{false, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d sectors, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Found != w.found || got[i].Object != w.id {
+			t.Errorf("sector %d: got %v, %t, want %v, %t", i, got[i].Object, got[i].Found, w.id, w.found)
+		}
+	}
+
+	// A single sector holds the nearest object.
+	got = db.NearestPerSector(5.0001, 5, 3, 1)
+	if len(got) != 1 || !got[0].Found || got[0].Object != 9 {
+		t.Errorf("single sector: got %+v, want object 9", got)
+	}
+	if got := db.NearestPerSector(5, 5, 3, 0); got != nil {
+		t.Errorf("no sectors: got %+v, want nil", got)
+	}
+}
+
+func TestFindTwoNearestInRadius(t *testing.T) {
+	var tests = []struct {
+		x, y, radius      float64
+		first, second     int
+		foundFirst, found bool
+	}{
+		{5, 5, 3, 3, 2, true, true},
+		{5, 5, 1.8, 3, 2, true, true},
+		{5, 5, 1.2, 3, 0, true, false},
+		{5, 5, 0.5, 0, 0, false, false},
+		{8.5, 5, 2, 4, 5, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("x=%v,y=%v,r=%v", tt.x, tt.y, tt.radius), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 5, 5)      // ignored
+			db.Attach(2, 6.5, 5)    // second nearest
+			db.Attach(3, 5, 4)      // nearest
+			db.Attach(4, 9.5, 5)    // third nearest
+			db.Attach(5, 10.2, 5.5) // outside of the super-brick
+
+			first, second, foundFirst, foundSecond := db.FindTwoNearestInRadius(tt.x, tt.y, tt.radius, 1)
+			if first != tt.first || second != tt.second || foundFirst != tt.foundFirst || foundSecond != tt.found {
+				t.Errorf("got %v, %v, %t, %t, want %v, %v, %t, %t",
+					first, second, foundFirst, foundSecond, tt.first, tt.second, tt.foundFirst, tt.found)
+			}
+		})
+	}
+}
+
+func TestFindNearestVisible(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 6, 5)   // nearest, behind the wall
+	db.Attach(2, 5, 3.5) // visible
+	db.Attach(3, 3, 5)   // visible, farther
+	db.Attach(4, 7.5, 5) // behind the wall
+	db.Attach(5, 5, 9)   // out of radius
+
+	// Vertical wall at x=5.5, from y=4 to y=6.
+	behindWall := func(x0, y0, x1, y1 float64) bool {
+		if (x0-5.5)*(x1-5.5) >= 0 {
+			return false // both ends on the same side
+		}
+		y := y0 + (y1-y0)*(5.5-x0)/(x1-x0)
+		return y >= 4 && y <= 6
+	}
+	visible := func(x0, y0, x1, y1 float64) bool {
+		return !behindWall(x0, y0, x1, y1)
+	}
+
+	var tests = []struct {
+		x, y, radius float64
+		want         int
+		wantFound    bool
+	}{
+		{5, 5, 3, 2, true},
+		{5, 5, 1.2, 0, false},
+		{6.5, 5, 3, 1, true}, // same side of the wall
+		{5, 3, 0.6, 2, true}, // visible nearest
+		{5, 5, 1.6, 2, true}, // object 2 is the only visible one in range
+		{4, 5, 2.5, 3, true},
+		{9, 9, 1, 0, false}, // nothing in range
+	}
+	for _, tt := range tests {
+		got, found := db.FindNearestVisible(tt.x, tt.y, tt.radius, visible)
+		if got != tt.want || found != tt.wantFound {
+			t.Errorf("FindNearestVisible(%v, %v, %v) = %v, %t, want %v, %t",
+				tt.x, tt.y, tt.radius, got, found, tt.want, tt.wantFound)
+		}
+	}
+
+	// Without the wall, the geometrically nearest object is found.
+	all := func(x0, y0, x1, y1 float64) bool { return true }
+	if got, _ := db.FindNearestVisible(5, 5, 3, all); got != 1 {
+		t.Errorf("FindNearestVisible() without walls = %v, want 1", got)
+	}
+}
+
+func TestNearestTiebreak(t *testing.T) {
+	// Exactly equidistant from (5,5), in different bins and in the same bin.
+	pts := [][2]float64{{7.5, 5}, {2.5, 5}, {5, 7.5}, {5, 2.5}, {6.5, 7}, {7, 6.5}}
+
+	perms := [][]int{
+		{0, 1, 2, 3, 4, 5},
+		{5, 4, 3, 2, 1, 0},
+		{2, 0, 5, 1, 3, 4},
+		{4, 5, 1, 0, 3, 2},
+	}
+	for _, perm := range perms {
+		t.Run(fmt.Sprint(perm), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			proxies := make([]*Proxy[int], len(perm))
+			for i, id := range perm {
+				proxies[i] = db.Attach(id, pts[id][0], pts[id][1])
+			}
+			// Reorder the bins lists, without changing the attach order.
+			for i := len(proxies) - 1; i >= 0; i-- {
+				x, y := proxies[i].Location()
+				db.Update(proxies[i], -1, -1)
+				db.Update(proxies[i], x, y)
+			}
+
+			want := perm[0] // the first attached object
+			check := func(name string, got int, found bool) {
+				t.Helper()
+				if !found || got != want {
+					t.Errorf("%s = %v, %t, want %v, true", name, got, found, want)
+				}
+			}
+			got, found := db.FindNearestInRadius(5, 5, 3, -1)
+			check("FindNearestInRadius", got, found)
+			got, found = db.FindNearestWithinBins(5, 5, 3, -1)
+			check("FindNearestWithinBins", got, found)
+
+			db.Freeze()
+			got, found = db.FindNearestInRadius(5, 5, 3, -1)
+			check("frozen FindNearestInRadius", got, found)
+		})
+	}
+}
+
+func TestNearestAmong(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	p1 := db.Attach(1, 1, 1)
+	p2 := db.Attach(2, 5, 5)
+	p3 := db.Attach(3, 9, 9)
+	p4 := db.Attach(4, 5, 6) // ties with p2 from (5, 5.5)
+	p5 := db.Attach(5, 20, 20)
+
+	var tests = []struct {
+		x, y       float64
+		candidates []*Proxy[int]
+		want       int
+	}{
+		{0, 0, []*Proxy[int]{p1, p2, p3}, 1},
+		{0, 0, []*Proxy[int]{p2, p3}, 2},
+		{8, 8, []*Proxy[int]{p1, p2, p3, p4}, 3},
+		{5, 5.5, []*Proxy[int]{p4, p2}, 2},
+		{5, 5.5, []*Proxy[int]{p2, p4}, 2},
+		{30, 30, []*Proxy[int]{p1, p5}, 5},
+		{5, 5, nil, 0},
+	}
+	for _, tt := range tests {
+		got, ok := db.NearestAmong(tt.x, tt.y, tt.candidates)
+		if tt.want == 0 {
+			if ok || got != nil {
+				t.Errorf("NearestAmong(%v, %v) = %v, %t, want nil, false", tt.x, tt.y, got, ok)
+			}
+			continue
+		}
+		if !ok || got.Object() != tt.want {
+			t.Errorf("NearestAmong(%v, %v) = %v, %t, want %d", tt.x, tt.y, got, ok, tt.want)
+		}
+	}
+
+	// Stored locations are used.
+	db.Update(p3, 0.5, 0.5)
+	if got, _ := db.NearestAmong(0, 0, []*Proxy[int]{p1, p3}); got != p3 {
+		t.Errorf("NearestAmong() after Update = %v, want 3", got.Object())
+	}
+}
+
+func TestPopNearestInRadius(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)
+	db.Attach(2, 6, 5)
+	db.Attach(3, 5, 3)
+	db.Attach(4, 3, 5) // ties with 3
+	db.Attach(5, 5, 8) // out of radius
+	db.Attach(6, 5.5, 5)
+
+	var popped []int
+	for {
+		obj, ok := db.PopNearestInRadius(5, 5, 2.5, 6)
+		if !ok {
+			break
+		}
+		popped = append(popped, obj)
+	}
+	if got, want := fmt.Sprint(popped), "[1 2 3 4]"; got != want {
+		t.Errorf("popped %s, want %s", got, want)
+	}
+	if obj, ok := db.FindNearestInRadius(5, 5, 2.5, 6); ok {
+		t.Errorf("object %d left within radius", obj)
+	}
+	if db.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", db.Len())
+	}
+	ids := make(idset)
+	db.ForEachObject(ids.storeID)
+	ids.assertContains(t, 5)
+	ids.assertContains(t, 6)
+}
+
+func TestFindNearestBudgeted(t *testing.T) {
+	// Sparse database, bins of size 1x1. All searches extend outside of the
+	// super-brick, so the other bin is visited first, then bins ring by ring.
+	db := NewDB[int](0, 0, 20, 20, 20, 20)
+	db.Attach(1, 18.5, 18.5)
+	db.Attach(2, 2.5, 2.5)
+	db.Attach(3, 2.6, 2.5)
+
+	var tests = []struct {
+		name            string
+		x, y, radius    float64
+		maxBins         int
+		want            int
+		found, complete bool
+	}{
+		{"budget too small", 15.5, 15.5, 10, 5, 0, false, false},
+		{"found but not proven", 15.5, 15.5, 10, 41, 1, true, false},
+		{"proven", 15.5, 15.5, 10, 100, 1, true, true},
+		{"nearest in first bin", 2.9, 2.5, 10, 2, 3, true, false},
+		{"proven with few bins", 2.9, 2.5, 10, 10, 3, true, true},
+		{"nothing within radius", 10.5, 10.5, 2, 100, 0, false, true},
+		{"zero budget", 2.5, 2.5, 10, 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, complete := db.FindNearestBudgeted(tt.x, tt.y, tt.radius, tt.maxBins, 0)
+			if got != tt.want || found != tt.found || complete != tt.complete {
+				t.Errorf("FindNearestBudgeted() = %v, %t, %t, want %v, %t, %t", got, found, complete, tt.want, tt.found, tt.complete)
+			}
+		})
+	}
+
+	// With an unlimited budget, the search is always complete and matches
+	// FindNearestInRadius.
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		db.Attach(10+i, rng.Float64()*24-2, rng.Float64()*24-2)
+	}
+	for i := 0; i < 200; i++ {
+		x, y, r := rng.Float64()*24-2, rng.Float64()*24-2, rng.Float64()*8
+		want, wantFound := db.FindNearestInRadius(x, y, r, 0)
+		got, found, complete := db.FindNearestBudgeted(x, y, r, 1000, 0)
+		if got != want || found != wantFound || !complete {
+			t.Fatalf("FindNearestBudgeted(%v, %v, %v) = %v, %t, %t, want %v, %t, true", x, y, r, got, found, complete, want, wantFound)
+		}
+	}
+}
+
+func TestFindNearestOfLayer(t *testing.T) {
+	const (
+		ally uint32 = 1 << iota
+		enemy
+		neutral
+	)
+
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.AttachLayer(1, 5, 5, enemy) // ignored
+	db.AttachLayer(2, 5.5, 5, ally)
+	db.AttachLayer(3, 5, 6, neutral)
+	db.AttachLayer(4, 7, 5, enemy)
+	db.AttachLayer(5, 3, 5, enemy) // tied with 4, attached later
+	db.AttachLayer(6, 5, 4.5, ally|enemy)
+	db.AttachLayer(7, -0.5, 5, neutral) // in the other bin
+
+	var tests = []struct {
+		radius float64
+		mask   uint32
+		want   int
+		found  bool
+	}{
+		{3, enemy, 6, true},
+		{0.4, enemy, 0, false},
+		{3, ally, 2, true},
+		{3, neutral, 3, true},
+		{3, 0, 0, false},
+		{3, AllLayers, 2, true},
+	}
+	for _, tt := range tests {
+		got, found := db.FindNearestOfLayer(5, 5, tt.radius, tt.mask, 1)
+		if got != tt.want || found != tt.found {
+			t.Errorf("FindNearestOfLayer(radius=%v, mask=%03b) = %d, %t, want %d, %t",
+				tt.radius, tt.mask, got, found, tt.want, tt.found)
+		}
+	}
+
+	// Without object 6, the enemies 4 and 5 are tied.
+	db2 := NewDB[int](0, 0, 10, 10, 5, 5)
+	db2.AttachLayer(2, 5.5, 5, ally)
+	db2.AttachLayer(4, 7, 5, enemy)
+	db2.AttachLayer(5, 3, 5, enemy)
+	db2.AttachLayer(7, -0.5, 5, enemy)
+	if got, found := db2.FindNearestOfLayer(5, 5, 3, enemy, 0); got != 4 || !found {
+		t.Errorf("FindNearestOfLayer() = %d, %t, want 4, true", got, found)
+	}
+	if got, found := db2.FindNearestOfLayer(0, 5, 3, enemy, 0); got != 7 || !found {
+		t.Errorf("FindNearestOfLayer() near the other bin = %d, %t, want 7, true", got, found)
+	}
+}
@@ -0,0 +1,202 @@
+package lq
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An Option configures a database created with New.
+type Option func(*config)
+
+// config holds the database settings that can be configured with options.
+type config struct {
+	xorg, yorg   float64
+	xsize, ysize float64
+	xdiv, ydiv   int
+	oob          OutOfBoundsPolicy
+	insertAtTail bool
+	order        IndexOrder
+	alwaysOther  bool
+	bruteForce   int
+}
+
+// WithBounds sets the position and size of the super-brick: (xorg,yorg) is
+// its minimum corner, xsize and ysize are its width and height, which must be
+// strictly positive. The default super-brick is the unit square with its
+// minimum corner at the origin.
+func WithBounds(xorg, yorg, xsize, ysize float64) Option {
+	return func(cfg *config) {
+		cfg.xorg, cfg.yorg = xorg, yorg
+		cfg.xsize, cfg.ysize = xsize, ysize
+	}
+}
+
+// WithDivisions sets the number of subdivisions (sub-bricks) of the
+// super-brick along each axis, which must be at least 1. The default is a
+// single sub-brick.
+func WithDivisions(xdiv, ydiv int) Option {
+	return func(cfg *config) {
+		cfg.xdiv, cfg.ydiv = xdiv, ydiv
+	}
+}
+
+// OutOfBoundsPolicy defines how a database handles objects located outside of
+// its super-brick.
+type OutOfBoundsPolicy int
+
+const (
+	// CatchAll puts the objects outside of the super-brick in the "other"
+	// bin, which is searched by any query that extends outside of the
+	// super-brick. This is the default.
+	CatchAll OutOfBoundsPolicy = iota
+	// ClampToEdge puts the objects outside of the super-brick in the nearest
+	// bin on the super-brick border. Queries then visit the border bins
+	// nearest to them when they extend outside of the super-brick.
+	ClampToEdge
+	// Reject forbids attaching or moving objects outside of the super-brick:
+	// AttachChecked and UpdateChecked return ErrOutOfBounds, while Attach,
+	// Update and the other methods locating objects panic. Objects moved out
+	// of the super-brick by Recenter are still put in the "other" bin.
+	Reject
+)
+
+// ErrOutOfBounds is returned when attaching or moving an object outside of the
+// super-brick of a database with the Reject policy.
+var ErrOutOfBounds = errors.New("lq: location outside of the super-brick")
+
+// WithOutOfBoundsPolicy sets how the database handles objects located outside
+// of the super-brick. The default is CatchAll.
+func WithOutOfBoundsPolicy(policy OutOfBoundsPolicy) Option {
+	return func(cfg *config) {
+		cfg.oob = policy
+	}
+}
+
+// WithInsertAtTail makes the database insert objects at the tail of their bin,
+// rather than at its head.
+//
+// Objects of a bin are then iterated in the order in which they entered the
+// bin (FIFO order), which makes iteration order more predictable, for example
+// in deterministic simulations. Bins don't keep a pointer to their tail, which
+// would add a field to each bin, so the cost of moving an object to another bin
+// becomes proportional to the number of objects in that bin.
+func WithInsertAtTail() Option {
+	return func(cfg *config) {
+		cfg.insertAtTail = true
+	}
+}
+
+// WithAlwaysCheckOther makes radius queries always search the "other" bin,
+// even when the query region doesn't seem to extend outside of the
+// super-brick.
+//
+// This trades speed for correctness: each query then tests every object of
+// the "other" bin, which is only worth it for applications keeping many
+// objects out of bounds, some of them close to the super-brick borders, that
+// must never be missed by queries centered inside the super-brick.
+func WithAlwaysCheckOther() Option {
+	return func(cfg *config) {
+		cfg.alwaysOther = true
+	}
+}
+
+// WithBruteForceThreshold makes ForEachWithinRadius test all objects, rather
+// than only those in the bins overlapping the search circle, when the database
+// holds less than n objects.
+//
+// With very few objects the cost of a query is dominated by the bins
+// traversal, and testing all objects is cheaper, all the more when the search
+// radius spans many bins. To that end, the database keeps track of the
+// non-empty bins as long as there are less than 2n of them, which slightly
+// slows down modifications. The best threshold depends on the number of bins
+// and on the typical search radius, it should be chosen by benchmarking. The
+// default is 0, which disables brute force.
+func WithBruteForceThreshold(n int) Option {
+	return func(cfg *config) {
+		cfg.bruteForce = n
+	}
+}
+
+// IndexOrder defines the layout of the bins in memory.
+type IndexOrder int
+
+const (
+	// ColumnMajor stores the bins column by column: bin (ix,iy) is at index
+	// ix*ydiv+iy, bins with the same x coordinate being contiguous. This is
+	// the default.
+	ColumnMajor IndexOrder = iota
+	// RowMajor stores the bins row by row: bin (ix,iy) is at index
+	// iy*xdiv+ix, bins with the same y coordinate being contiguous.
+	RowMajor
+)
+
+// WithIndexOrder sets the layout of the bins in memory. The default is
+// ColumnMajor, RowMajor matches the layout of most external tile data, which
+// avoids a transposition when exchanging per-bin data with it.
+func WithIndexOrder(order IndexOrder) Option {
+	return func(cfg *config) {
+		cfg.order = order
+	}
+}
+
+// New creates a new database configured with the given options, allocates the
+// bin array, and returns the DB object.
+//
+// For example:
+//
+//	db := New[myObject](WithBounds(0, 0, 100, 50), WithDivisions(20, 10))
+//
+// is equivalent to:
+//
+//	db := NewDB[myObject](0, 0, 100, 50, 20, 10)
+//
+// New panics if the super-brick size isn't strictly positive, or if there is
+// less than one subdivision along an axis.
+func New[T comparable](opts ...Option) *DB[T] {
+	cfg := config{
+		xsize: 1,
+		ysize: 1,
+		xdiv:  1,
+		ydiv:  1,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if !(cfg.xsize > 0) || !(cfg.ysize > 0) {
+		panic(fmt.Sprintf("lq: super-brick size must be strictly positive, got %vx%v", cfg.xsize, cfg.ysize))
+	}
+	if cfg.xdiv < 1 || cfg.ydiv < 1 {
+		panic(fmt.Sprintf("lq: super-brick divisions must be at least 1, got %dx%d", cfg.xdiv, cfg.ydiv))
+	}
+
+	db := &DB[T]{
+		xorg:         cfg.xorg,
+		yorg:         cfg.yorg,
+		szx:          cfg.xsize,
+		szy:          cfg.ysize,
+		xdiv:         cfg.xdiv,
+		ydiv:         cfg.ydiv,
+		xscale:       float64(cfg.xdiv) / cfg.xsize,
+		yscale:       float64(cfg.ydiv) / cfg.ysize,
+		bins:         make([]*Proxy[T], cfg.xdiv*cfg.ydiv),
+		gens:         make([]uint64, cfg.xdiv*cfg.ydiv),
+		oob:          cfg.oob,
+		insertAtTail: cfg.insertAtTail,
+		order:        cfg.order,
+		alwaysOther:  cfg.alwaysOther,
+		bruteForce:   cfg.bruteForce,
+		occupiedOK:   cfg.bruteForce > 0,
+	}
+	db.setStrides()
+	return db
+}
+
+// setStrides computes the bins strides, according to the index order and the
+// number of subdivisions.
+func (db *DB[T]) setStrides() {
+	if db.order == RowMajor {
+		db.xstride, db.ystride = 1, db.xdiv
+	} else {
+		db.xstride, db.ystride = db.ydiv, 1
+	}
+}
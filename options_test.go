@@ -0,0 +1,360 @@
+package lq
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	var tests = []struct {
+		orgx, orgy float64
+		szx, szy   float64
+		divx, divy int
+	}{
+		{0, 0, 10, 10, 5, 5},
+		{-5, 3, 20, 10, 4, 2},
+		{0, 0, 10, 10, 1, 1},
+		{100, 100, 1, 50, 1, 25},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("bounds=(%v,%v,%v,%v),divs=(%d,%d)", tt.orgx, tt.orgy, tt.szx, tt.szy, tt.divx, tt.divy)
+		t.Run(name, func(t *testing.T) {
+			got := New[int](WithBounds(tt.orgx, tt.orgy, tt.szx, tt.szy), WithDivisions(tt.divx, tt.divy))
+			want := NewDB[int](tt.orgx, tt.orgy, tt.szx, tt.szy, tt.divx, tt.divy)
+
+			if got.xorg != want.xorg || got.yorg != want.yorg || got.szx != want.szx || got.szy != want.szy ||
+				got.xdiv != want.xdiv || got.ydiv != want.ydiv || len(got.bins) != len(want.bins) {
+				t.Fatalf("New() and NewDB() created different databases")
+			}
+
+			// Both databases must behave the same.
+			for i := 0; i < 50; i++ {
+				x := tt.orgx - tt.szx/4 + float64(i%10)*tt.szx/6
+				y := tt.orgy - tt.szy/4 + float64(i/10)*tt.szy/3
+				got.Attach(i, x, y)
+				want.Attach(i, x, y)
+			}
+			cx, cy, r := tt.orgx+tt.szx/2, tt.orgy+tt.szy/2, tt.szx/3
+			gotIDs, wantIDs := make(idset), make(idset)
+			got.ForEachWithinRadius(cx, cy, r, gotIDs.storeID)
+			want.ForEachWithinRadius(cx, cy, r, wantIDs.storeID)
+			for i := 0; i < 50; i++ {
+				_, ok := wantIDs[i]
+				gotIDs.assertIsContained(t, i, ok)
+			}
+			if got.OtherCount() != want.OtherCount() {
+				t.Errorf("OtherCount() = %d, want %d", got.OtherCount(), want.OtherCount())
+			}
+		})
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	db := New[int]()
+	db.Attach(1, 0.5, 0.5)
+	db.Attach(2, 1.5, 0.5)
+
+	if db.OtherCount() != 1 {
+		t.Errorf("OtherCount() = %d, want 1", db.OtherCount())
+	}
+	if len(db.bins) != 1 {
+		t.Errorf("got %d bins, want 1", len(db.bins))
+	}
+}
+
+func TestOutOfBoundsPolicy(t *testing.T) {
+	newDB := func(policy OutOfBoundsPolicy) *DB[int] {
+		db := New[int](WithBounds(0, 0, 10, 10), WithDivisions(5, 5), WithOutOfBoundsPolicy(policy))
+		db.Attach(1, 5, 5)
+		return db
+	}
+
+	t.Run("CatchAll", func(t *testing.T) {
+		db := newDB(CatchAll)
+		p, err := db.AttachChecked(2, -3, 5)
+		if err != nil {
+			t.Fatalf("AttachChecked() error = %v, want nil", err)
+		}
+		if ix, iy, inOther := db.ProxyBin(p); !inOther {
+			t.Errorf("ProxyBin() = %d, %d, %t, want the other bin", ix, iy, inOther)
+		}
+		if err := db.UpdateChecked(p, 15, 15); err != nil {
+			t.Errorf("UpdateChecked() error = %v, want nil", err)
+		}
+		if db.OtherCount() != 1 {
+			t.Errorf("OtherCount() = %d, want 1", db.OtherCount())
+		}
+	})
+
+	t.Run("ClampToEdge", func(t *testing.T) {
+		db := newDB(ClampToEdge)
+		p := db.Attach(2, -3, 5)
+		if ix, iy, inOther := db.ProxyBin(p); ix != 0 || iy != 2 || inOther {
+			t.Errorf("ProxyBin() = %d, %d, %t, want 0, 2, false", ix, iy, inOther)
+		}
+		if db.OtherCount() != 0 {
+			t.Errorf("OtherCount() = %d, want 0", db.OtherCount())
+		}
+		if x, y := p.Location(); x != -3 || y != 5 {
+			t.Errorf("Location() = (%v, %v), want (-3, 5)", x, y)
+		}
+
+		// Queries near the clamped object find it, even those not overlapping
+		// any bin of the super-brick.
+		for _, c := range [][2]float64{{-3, 5}, {-0.5, 5}, {-5, 4}} {
+			ids := make(idset)
+			db.ForEachWithinRadius(c[0], c[1], 3, ids.storeID)
+			ids.assertContains(t, 2)
+			ids.assertNotContains(t, 1)
+		}
+		if got, found := db.FindNearestInRadius(-4, 6, 2, 0); !found || got != 2 {
+			t.Errorf("FindNearestInRadius() = %v, %t, want 2, true", got, found)
+		}
+
+		db.Update(p, 12, 12)
+		if ix, iy, inOther := db.ProxyBin(p); ix != 4 || iy != 4 || inOther {
+			t.Errorf("ProxyBin() = %d, %d, %t, want 4, 4, false", ix, iy, inOther)
+		}
+		ids := make(idset)
+		db.ForEachWithinRadius(13, 13, 2, ids.storeID)
+		ids.assertContains(t, 2)
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		db := newDB(Reject)
+		p, err := db.AttachChecked(2, -3, 5)
+		if err != ErrOutOfBounds || p != nil {
+			t.Errorf("AttachChecked() = %v, %v, want nil, ErrOutOfBounds", p, err)
+		}
+		if db.Len() != 1 {
+			t.Errorf("Len() = %d, want 1", db.Len())
+		}
+
+		p, err = db.AttachChecked(2, 3, 5)
+		if err != nil {
+			t.Fatalf("AttachChecked() error = %v, want nil", err)
+		}
+		if err := db.UpdateChecked(p, 3, 11); err != ErrOutOfBounds {
+			t.Errorf("UpdateChecked() error = %v, want ErrOutOfBounds", err)
+		}
+		if x, y := p.Location(); x != 3 || y != 5 {
+			t.Errorf("after rejected update, Location() = (%v, %v), want (3, 5)", x, y)
+		}
+
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Update() didn't panic")
+			}
+			if db.OtherCount() != 0 {
+				t.Errorf("OtherCount() = %d, want 0", db.OtherCount())
+			}
+		}()
+		db.Update(p, 3, 11)
+	})
+}
+
+func TestWithAlwaysCheckOther(t *testing.T) {
+	db := New[int](WithBounds(0, 0, 10, 10), WithDivisions(5, 5), WithAlwaysCheckOther())
+	db.Attach(1, -0.1, 5) // just out of bounds
+	db.Attach(2, 10.1, 5) // out of bounds, out of radius
+
+	ids := make(idset)
+	db.ForEachWithinRadius(1, 5, 1.5, ids.storeID)
+	ids.assertContains(t, 1)
+	ids.assertNotContains(t, 2)
+
+	if got, ok := db.FindNearestInRadius(1, 5, 1.5, 0); !ok || got != 1 {
+		t.Errorf("FindNearestInRadius() = %v, %t, want 1", got, ok)
+	}
+}
+
+func TestWithBruteForceThreshold(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ref := New[int](WithBounds(0, 0, 10, 10), WithDivisions(5, 5))
+	db := New[int](WithBounds(0, 0, 10, 10), WithDivisions(5, 5), WithBruteForceThreshold(8))
+
+	var refProxies, proxies []*Proxy[int]
+	check := func(step string) {
+		t.Helper()
+		for i := 0; i < 20; i++ {
+			x, y, r := rng.Float64()*14-2, rng.Float64()*14-2, rng.Float64()*5
+			want, got := make(idset), make(map[int]int)
+			ref.ForEachWithinRadius(x, y, r, want.storeID)
+			db.ForEachWithinRadius(x, y, r, func(id int, _ float64) { got[id]++ })
+			if len(got) != len(want) {
+				t.Fatalf("%s: ForEachWithinRadius(%v, %v, %v) found %d objects, want %d", step, x, y, r, len(got), len(want))
+			}
+			for id, n := range got {
+				want.assertContains(t, id)
+				if n != 1 {
+					t.Fatalf("%s: object %d visited %d times", step, id, n)
+				}
+			}
+		}
+	}
+
+	// Cross the threshold up, then down, moving objects around.
+	for i := 0; i < 20; i++ {
+		x, y := rng.Float64()*12-1, rng.Float64()*12-1
+		refProxies = append(refProxies, ref.Attach(i, x, y))
+		proxies = append(proxies, db.Attach(i, x, y))
+		check(fmt.Sprintf("attach %d", i))
+	}
+	for i := 0; i < 20; i++ {
+		j := rng.Intn(len(proxies))
+		x, y := rng.Float64()*12-1, rng.Float64()*12-1
+		ref.Update(refProxies[j], x, y)
+		db.Update(proxies[j], x, y)
+		if i%2 == 0 {
+			ref.Detach(refProxies[i])
+			db.Detach(proxies[i])
+		}
+		check(fmt.Sprintf("update %d", i))
+	}
+	ref.Regrid(3, 3)
+	db.Regrid(3, 3)
+	check("regrid")
+	for i := 1; i < 20; i += 2 {
+		ref.Detach(refProxies[i])
+		db.Detach(proxies[i])
+		check(fmt.Sprintf("detach %d", i))
+	}
+
+	// Move a few objects around, below the threshold.
+	refProxies, proxies = refProxies[:0], proxies[:0]
+	for i := 20; i < 25; i++ {
+		refProxies = append(refProxies, ref.Attach(i, 5, 5))
+		proxies = append(proxies, db.Attach(i, 5, 5))
+	}
+	for i := 0; i < 50; i++ {
+		j := rng.Intn(len(proxies))
+		x, y := rng.Float64()*12-1, rng.Float64()*12-1
+		ref.Update(refProxies[j], x, y)
+		db.Update(proxies[j], x, y)
+		check(fmt.Sprintf("move %d", i))
+	}
+}
+
+func TestWithInsertAtTail(t *testing.T) {
+	db := New[int](WithBounds(0, 0, 10, 10), WithDivisions(5, 5), WithInsertAtTail())
+	db.Attach(1, 1, 1)
+	db.Attach(2, 1.2, 1.2)
+	p3 := db.Attach(3, 5, 5) // moved to the first bin later
+	db.Attach(4, 1.4, 1.4)
+	db.Attach(5, -1, -1)
+	db.Attach(6, -2, -2)
+	db.Update(p3, 1.6, 1.6)
+
+	order := func() string {
+		var ids []int
+		db.ForEachObject(func(id int, _ float64) { ids = append(ids, id) })
+		return fmt.Sprint(ids)
+	}
+	if got, want := order(), "[1 2 4 3 5 6]"; got != want {
+		t.Errorf("iteration order = %s, want %s", got, want)
+	}
+
+	// Re-binning all objects preserves their order.
+	db.Recenter(5, 5)
+	if got, want := order(), "[1 2 4 3 5 6]"; got != want {
+		t.Errorf("after Recenter, iteration order = %s, want %s", got, want)
+	}
+}
+
+func TestWithIndexOrder(t *testing.T) {
+	for _, order := range []IndexOrder{ColumnMajor, RowMajor} {
+		t.Run(fmt.Sprintf("order=%d", order), func(t *testing.T) {
+			db := New[int](WithBounds(0, 0, 8, 6), WithDivisions(4, 3), WithIndexOrder(order))
+
+			// Check the layout of the bins.
+			p := db.Attach(0, 5, 3) // bin (2,1)
+			want := 2*3 + 1
+			if order == RowMajor {
+				want = 1*4 + 2
+			}
+			if db.bins[want] != p {
+				t.Errorf("object not found at index %d", want)
+			}
+			db.Detach(p)
+
+			rng := rand.New(rand.NewSource(1))
+			pos := make(map[int][2]float64)
+			for i := 0; i < 200; i++ {
+				pos[i] = [2]float64{8 * rng.Float64(), 6 * rng.Float64()}
+				db.Attach(i, pos[i][0], pos[i][1])
+			}
+
+			// Compare queries with brute force.
+			for q := 0; q < 50; q++ {
+				x, y, r := 10*rng.Float64()-1, 8*rng.Float64()-1, 3*rng.Float64()
+				ids := make(idset)
+				db.ForEachWithinRadius(x, y, r, ids.storeID)
+				for id, p := range pos {
+					in := (p[0]-x)*(p[0]-x)+(p[1]-y)*(p[1]-y) < r*r
+					ids.assertIsContained(t, id, in)
+				}
+
+				nearest, found := db.FindNearestWithinBins(x, y, 10, -1)
+				bestID, bestSqDist := -1, math.MaxFloat64
+				for id, p := range pos {
+					if d := (p[0]-x)*(p[0]-x) + (p[1]-y)*(p[1]-y); d < bestSqDist {
+						bestID, bestSqDist = id, d
+					}
+				}
+				if !found || nearest != bestID {
+					t.Errorf("FindNearestWithinBins(%v, %v) = %v, %t, want %v", x, y, nearest, found, bestID)
+				}
+			}
+		})
+	}
+}
+
+func TestNewInvalidSize(t *testing.T) {
+	var tests = []struct {
+		xsize, ysize float64
+	}{
+		{0, 10},
+		{10, 0},
+		{0, 0},
+		{-1, 10},
+		{10, -0.5},
+		{math.NaN(), 10},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("size=%vx%v", tt.xsize, tt.ysize), func(t *testing.T) {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.HasPrefix(msg, "lq: super-brick size") {
+					t.Errorf("NewDB() panicked with %q, want a super-brick size error", msg)
+				}
+			}()
+			NewDB[int](0, 0, tt.xsize, tt.ysize, 5, 5)
+		})
+	}
+}
+
+func TestNewInvalidDivisions(t *testing.T) {
+	var tests = []struct {
+		xdiv, ydiv int
+	}{
+		{0, 5},
+		{5, 0},
+		{0, 0},
+		{-1, 5},
+		{5, -3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("divisions=%dx%d", tt.xdiv, tt.ydiv), func(t *testing.T) {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.HasPrefix(msg, "lq: super-brick divisions") {
+					t.Errorf("New() panicked with %q, want a super-brick divisions error", msg)
+				}
+			}()
+			New[int](WithBounds(0, 0, 10, 10), WithDivisions(tt.xdiv, tt.ydiv))
+		})
+	}
+}
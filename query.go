@@ -0,0 +1,666 @@
+package lq
+
+import (
+	"math"
+	"sort"
+)
+
+// forEachCandidate calls fn for each proxy in the bins overlapping the
+// rectangle [x0,x1]×[y0,y1], including the proxies of the "other" bin if the
+// rectangle extends outside the super-brick. Proxies are candidates, it's up
+// to fn to test whether they actually match the query.
+func (db *DB[T]) forEachCandidate(x0, y0, x1, y1 float64, fn func(cp *Proxy[T])) {
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x0, y0, x1, y1)
+
+	if outside {
+		for cp := db.other; cp != nil; cp = cp.next {
+			fn(cp)
+		}
+	}
+
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			for cp := db.bins[db.coordsToIndex(i, j)]; cp != nil; cp = cp.next {
+				fn(cp)
+			}
+		}
+	}
+}
+
+// ForEachWithinRadiusLayer is like ForEachWithinRadius but only considers the
+// objects belonging to at least one of the layers in mask.
+//
+// Objects whose layer isn't in mask are skipped before their distance to the
+// center of the search circle is computed.
+func (db *DB[T]) ForEachWithinRadiusLayer(x, y, radius float64, mask uint32, f Func[T]) {
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		if cp.layer&mask == 0 {
+			return
+		}
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if sqDist < sqRadius {
+			f(cp.object, sqDist)
+		}
+	})
+}
+
+// ForEachWithinRadiusMask applies a user-supplied function to the indices of
+// the objects within a certain locality, whose index is active.
+//
+// It only considers the objects attached with AttachIndexed. active reports
+// whether an index is active, for example by testing a bitset. Objects whose
+// index isn't active are skipped before their distance to the center of the
+// search circle is computed. f is called with the index of each object found.
+func (db *DB[T]) ForEachWithinRadiusMask(x, y, radius float64, active func(index int) bool, f func(index int, sqDist float64)) {
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		if cp.index == 0 || !active(cp.index-1) {
+			return
+		}
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if sqDist < sqRadius {
+			f(cp.index-1, sqDist)
+		}
+	})
+}
+
+// ForEachWithinChebyshev applies a user-supplied function to all objects whose
+// Chebyshev (or chessboard) distance to (x,y) is less than dist, that is
+// max(|dx|,|dy|) < dist.
+//
+// This matches objects within an axis-aligned square, which is the natural
+// neighborhood of grid-movement games, "within N king moves". Contrary to the
+// other queries, f gets called with the Chebyshev distance from (x,y) to each
+// object, not with a squared euclidean distance.
+func (db *DB[T]) ForEachWithinChebyshev(x, y, dist float64, f Func[T]) {
+	db.forEachCandidate(x-dist, y-dist, x+dist, y+dist, func(cp *Proxy[T]) {
+		d := math.Max(math.Abs(x-cp.x), math.Abs(y-cp.y))
+		if d < dist {
+			f(cp.object, d)
+		}
+	})
+}
+
+// ForEachWithinRadiusRingOrdered is like ForEachWithinRadius but objects are
+// visited in rings of bins of increasing distance from the bin containing
+// (x,y).
+//
+// Ring 0 is the bin containing (x,y), ring r is made of the bins at a
+// Chebyshev distance of r from it, and objects outside of the super-brick are
+// visited last. Within a ring the order is unspecified. This delivers objects
+// roughly from near to far, without the cost of sorting them.
+func (db *DB[T]) ForEachWithinRadiusRingOrdered(x, y, radius float64, f Func[T]) {
+	sqRadius := radius * radius
+	maxRings := db.xdiv
+	if db.ydiv > maxRings {
+		maxRings = db.ydiv
+	}
+	db.forEachRing(x, y, maxRings, func(head *Proxy[T]) {
+		traverseBinWithinRadius(head, x, y, sqRadius, f)
+	}, func(sqBound float64) bool {
+		// The bins not visited yet are out of the circle.
+		return sqBound >= sqRadius
+	})
+
+	if _, _, _, _, outside := db.binRange(x-radius, y-radius, x+radius, y+radius); outside {
+		traverseBinWithinRadius(db.other, x, y, sqRadius, f)
+	}
+}
+
+// ForEachWithinRadiusState is like ForEachWithinRadius but f also receives a
+// user-supplied state value.
+//
+// This allows passing per-query state to a function that doesn't need to be a
+// closure, which avoids allocating a closure when f would otherwise capture
+// variables escaping to the heap. Query statistics are not collected.
+func (db *DB[T]) ForEachWithinRadiusState(x, y, radius float64, f func(obj T, sqDist float64, state any), state any) {
+	sqRadius := radius * radius
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	if outside {
+		for cp := db.other; cp != nil; cp = cp.next {
+			if sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y); sqDist < sqRadius {
+				f(cp.object, sqDist, state)
+			}
+		}
+	}
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			for cp := db.bins[db.coordsToIndex(i, j)]; cp != nil; cp = cp.next {
+				if sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y); sqDist < sqRadius {
+					f(cp.object, sqDist, state)
+				}
+			}
+		}
+	}
+}
+
+// ForEachWithinRadiusProgress is like ForEachWithinRadius but it reports its
+// progress by calling onBin after each row of bins has been processed.
+//
+// binsTotal is the number of bins overlapped by the search circle, clipped to
+// the super-brick, and binsDone the number of those already processed. The
+// "other" bin, if it needs to be searched, is processed first and isn't
+// counted. This allows to report the progress of queries over large areas of
+// dense grids.
+func (db *DB[T]) ForEachWithinRadiusProgress(x, y, radius float64, f Func[T], onBin func(binsDone, binsTotal int)) {
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	if outside {
+		db.forEachObjectOutside(x, y, radius, f)
+	}
+
+	rowLen := maxBinY - minBinY + 1
+	total := (maxBinX - minBinX + 1) * rowLen
+	for i := minBinX; i <= maxBinX; i++ {
+		db.forEachInRadiusClipped(x, y, radius, f, i, minBinY, i, maxBinY)
+		onBin((i-minBinX+1)*rowLen, total)
+	}
+}
+
+// ReduceWithinRadius folds the objects within a certain locality of a
+// database into a single value.
+//
+// f is called for each object within radius of (x,y), as with
+// ForEachWithinRadius, with the value accumulated so far (init for the first
+// object) and returns the new accumulated value. ReduceWithinRadius returns
+// the final value, or init if there is no object within radius. This allows
+// computing aggregates, such as the total mass or the centroid of the objects
+// in a locality, in a single expression.
+func ReduceWithinRadius[T comparable, A any](db *DB[T], x, y, radius float64, init A, f func(acc A, obj T, sqDist float64) A) A {
+	acc := init
+	db.ForEachWithinRadius(x, y, radius, func(obj T, sqDist float64) {
+		acc = f(acc, obj, sqDist)
+	})
+	return acc
+}
+
+// ForEachWithinRadiusFacing is like ForEachWithinRadius but only considers the
+// objects in front of the center of the search circle.
+//
+// An object is in front of (x,y) if the vector from (x,y) to the object makes
+// a non-negative dot product with the facing vector (facingX,facingY), that is
+// if the object is in the half-plane the facing vector points to. The facing
+// vector doesn't need to be normalized.
+func (db *DB[T]) ForEachWithinRadiusFacing(x, y, radius, facingX, facingY float64, f Func[T]) {
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		dx, dy := cp.x-x, cp.y-y
+		if dx*facingX+dy*facingY < 0 {
+			return
+		}
+		if sqDist := dx*dx + dy*dy; sqDist < sqRadius {
+			f(cp.object, sqDist)
+		}
+	})
+}
+
+// ForEachWithinRadiusExceptRect is like ForEachWithinRadius but skips the
+// objects inside a rectangular hole.
+//
+// The hole is the axis-aligned rectangle going from (hx0,hy0) to (hx1,hy1),
+// its edges included. This matches a circle minus a safe zone in a single
+// pass over the bins overlapped by the circle.
+func (db *DB[T]) ForEachWithinRadiusExceptRect(x, y, radius float64, hx0, hy0, hx1, hy1 float64, f Func[T]) {
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		if cp.x >= hx0 && cp.x <= hx1 && cp.y >= hy0 && cp.y <= hy1 {
+			return
+		}
+		if sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y); sqDist < sqRadius {
+			f(cp.object, sqDist)
+		}
+	})
+}
+
+// ForEachWithinRectExceptCircle applies a user-supplied function to all
+// objects within an axis-aligned rectangle, but those within a keep-out
+// circle.
+//
+// The rectangle goes from (xmin,ymin) to (xmax,ymax), its edges included. The
+// objects whose distance to (cx,cy) is less than r are skipped, as they would
+// be matched by ForEachWithinRadius. f gets called with the squared distance
+// from (cx,cy) to each object. This matches a viewport minus a bubble around
+// the player in a single pass over the bins overlapped by the rectangle.
+func (db *DB[T]) ForEachWithinRectExceptCircle(xmin, ymin, xmax, ymax, cx, cy, r float64, f Func[T]) {
+	sqRadius := r * r
+	db.forEachCandidate(xmin, ymin, xmax, ymax, func(cp *Proxy[T]) {
+		if cp.x < xmin || cp.x > xmax || cp.y < ymin || cp.y > ymax {
+			return
+		}
+		if sqDist := (cx-cp.x)*(cx-cp.x) + (cy-cp.y)*(cy-cp.y); sqDist >= sqRadius {
+			f(cp.object, sqDist)
+		}
+	})
+}
+
+// ForEachWithinRadiusMutate is like ForEachWithinRadius but f receives the
+// proxies of the matched objects, and can move them.
+//
+// When f returns true the proxy is moved to (newX,newY), as with Update. The
+// matched proxies are all collected before f is first called, so that a proxy
+// moved into a bin not visited yet isn't visited twice: f is called once for
+// each object within the circle at the time of the call, with its squared
+// distance at that time, even if a previous call to f moved it. This allows
+// adjusting the positions of neighbors in one pass, for example to push them
+// apart.
+func (db *DB[T]) ForEachWithinRadiusMutate(x, y, radius float64, f func(p *Proxy[T], sqDist float64) (newX, newY float64, move bool)) {
+	db.mustNotBeFrozen("ForEachWithinRadiusMutate")
+
+	type match struct {
+		p      *Proxy[T]
+		sqDist float64
+	}
+	var matches []match
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		if sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y); sqDist < sqRadius {
+			matches = append(matches, match{cp, sqDist})
+		}
+	})
+
+	for _, m := range matches {
+		if newX, newY, move := f(m.p, m.sqDist); move {
+			db.Update(m.p, newX, newY)
+		}
+	}
+}
+
+// RadiusEmpty reports whether there is no object within a certain locality.
+//
+// The locality is specified as a circle with a given center and radius (see
+// ForEachWithinRadius). The search stops at the first object found within the
+// circle, which makes RadiusEmpty cheaper than counting objects, for example
+// to check whether an object can be spawned somewhere without overlapping any
+// other.
+func (db *DB[T]) RadiusEmpty(x, y, radius float64) bool {
+	sqRadius := radius * radius
+	inRadius := func(cp *Proxy[T]) bool {
+		for ; cp != nil; cp = cp.next {
+			if (x-cp.x)*(x-cp.x)+(y-cp.y)*(y-cp.y) < sqRadius {
+				return true
+			}
+		}
+		return false
+	}
+
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			if inRadius(db.bins[db.coordsToIndex(i, j)]) {
+				return false
+			}
+		}
+	}
+	return !outside || !inRadius(db.other)
+}
+
+// OtherBinKey is the key under which WithinRadiusByBin groups the objects that
+// are outside of the super-brick.
+var OtherBinKey = [2]int{-1, -1}
+
+// WithinRadiusByBin returns the objects within a certain locality, grouped by
+// bin.
+//
+// The locality is specified as a circle with a given center and radius (see
+// ForEachWithinRadius). Objects are keyed by the coordinates (ix,iy) of their
+// bin, objects outside of the super-brick being under OtherBinKey. Only bins
+// containing at least one object within the circle have an entry. This is
+// convenient for per-bin processing, but the map and one slice per bin are
+// allocated at each call.
+func (db *DB[T]) WithinRadiusByBin(x, y, radius float64) map[[2]int][]T {
+	res := make(map[[2]int][]T)
+	sqRadius := radius * radius
+	collect := func(cp *Proxy[T], key [2]int) {
+		for ; cp != nil; cp = cp.next {
+			if (x-cp.x)*(x-cp.x)+(y-cp.y)*(y-cp.y) < sqRadius {
+				res[key] = append(res[key], cp.object)
+			}
+		}
+	}
+
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	if outside {
+		collect(db.other, OtherBinKey)
+	}
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			collect(db.bins[db.coordsToIndex(i, j)], [2]int{i, j})
+		}
+	}
+	return res
+}
+
+// ForEachWithinOBB applies a user-supplied function to all objects within an
+// oriented bounding box (a rotated rectangle).
+//
+// The box is centered on (cx,cy), its half-width halfW and half-height halfH
+// are measured along its own axes, which are rotated by angle radians
+// (counterclockwise) relative to the global axes. Objects on the box border
+// are within the box. f gets called with the squared distance from the center
+// of the box to each object. Bins are selected with the axis-aligned bounding
+// box of the rotated box, each candidate object is then transformed into the
+// box's frame to be tested against its half-extents.
+func (db *DB[T]) ForEachWithinOBB(cx, cy, halfW, halfH, angle float64, f Func[T]) {
+	sin, cos := math.Sincos(angle)
+	ex := math.Abs(cos)*halfW + math.Abs(sin)*halfH
+	ey := math.Abs(sin)*halfW + math.Abs(cos)*halfH
+	db.forEachCandidate(cx-ex, cy-ey, cx+ex, cy+ey, func(cp *Proxy[T]) {
+		dx, dy := cp.x-cx, cp.y-cy
+		lx := dx*cos + dy*sin
+		ly := dy*cos - dx*sin
+		if math.Abs(lx) <= halfW && math.Abs(ly) <= halfH {
+			f(cp.object, dx*dx+dy*dy)
+		}
+	})
+}
+
+// Positioned is an object along with its location.
+type Positioned[T any] struct {
+	Obj  T
+	X, Y float64
+}
+
+// WithinRadiusPositions returns the objects within a certain locality, along
+// with their locations, as given during the last call to Attach or Update.
+//
+// The locality is specified as a circle with a given center and radius (see
+// ForEachWithinRadius). The returned slice is allocated at each call, its
+// order is unspecified.
+func (db *DB[T]) WithinRadiusPositions(x, y, radius float64) []Positioned[T] {
+	var res []Positioned[T]
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		if (x-cp.x)*(x-cp.x)+(y-cp.y)*(y-cp.y) < sqRadius {
+			res = append(res, Positioned[T]{Obj: cp.object, X: cp.x, Y: cp.y})
+		}
+	})
+	return res
+}
+
+// ForEachInBinNeighborhood applies f to all objects in the block of bins
+// centered on the bin containing the location (x,y).
+//
+// The block is made of (2*ring+1)² bins, so ring=0 only visits the bin
+// containing (x,y) while ring=1 visits the classic 3×3 neighborhood. The block
+// is clipped to the super-brick; when it extends past the super-brick borders
+// (or when (x,y) is outside of it), objects in the "other" bin are visited as
+// well. f gets called with the squared distance from (x,y) to each object.
+func (db *DB[T]) ForEachInBinNeighborhood(x, y float64, ring int, f Func[T]) {
+	cx, cy := db.binCoords(x, y)
+	xmin, ymin, xmax, ymax := cx-ring, cy-ring, cx+ring, cy+ring
+
+	outside := x < db.xorg || y < db.yorg || x >= db.xorg+db.szx || y >= db.yorg+db.szy
+	if xmin < 0 {
+		outside = true
+		xmin = 0
+	}
+	if ymin < 0 {
+		outside = true
+		ymin = 0
+	}
+	if xmax >= db.xdiv {
+		outside = true
+		xmax = db.xdiv - 1
+	}
+	if ymax >= db.ydiv {
+		outside = true
+		ymax = db.ydiv - 1
+	}
+
+	if outside {
+		db.other.traverseBinWithDist(x, y, f)
+	}
+	for i := xmin; i <= xmax; i++ {
+		for j := ymin; j <= ymax; j++ {
+			db.bins[db.coordsToIndex(i, j)].traverseBinWithDist(x, y, f)
+		}
+	}
+}
+
+// SelectKInRadius returns the first k objects, according to less, among the
+// objects within a certain locality.
+//
+// The locality is specified as a circle with a given center and radius (see
+// ForEachWithinRadius). less defines the order of the objects, so, for
+// example, ordering objects by decreasing priority returns the k objects with
+// the highest priority. The returned objects are sorted according to less.
+// Fewer than k objects are returned if the locality contains less than k
+// objects.
+func (db *DB[T]) SelectKInRadius(x, y, radius float64, k int, less func(a, b T) bool) []T {
+	if k <= 0 {
+		return nil
+	}
+
+	h := newBoundedHeap(k, less)
+	db.ForEachWithinRadius(x, y, radius, func(obj T, _ float64) {
+		h.push(obj)
+	})
+	return h.sorted()
+}
+
+// OtherBinDist is the bin distance reported by ForEachWithinRadiusBinDist for
+// objects that are outside of the super-brick.
+const OtherBinDist = math.MaxInt32
+
+// ForEachWithinRadiusBinDist is like ForEachWithinRadius but f also receives
+// the bin distance of each object.
+//
+// The bin distance is the Chebyshev distance between the bin of the object and
+// the bin containing (x,y) (or the nearest bin if (x,y) is outside the
+// super-brick): 0 for objects in the same bin, 1 for objects in the 8
+// surrounding bins, and so on. Objects outside of the super-brick have a bin
+// distance of OtherBinDist. Bin distances provide a cheap way to bucket
+// objects, for example by level of detail.
+func (db *DB[T]) ForEachWithinRadiusBinDist(x, y, radius float64, f func(obj T, sqDist float64, binDist int)) {
+	sqRadius := radius * radius
+	traverse := func(cp *Proxy[T], binDist int) {
+		for ; cp != nil; cp = cp.next {
+			sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+			if sqDist < sqRadius {
+				f(cp.object, sqDist, binDist)
+			}
+		}
+	}
+
+	cx, cy := db.binCoords(x, y)
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	if outside {
+		traverse(db.other, OtherBinDist)
+	}
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			binDist := abs(i - cx)
+			if d := abs(j - cy); d > binDist {
+				binDist = d
+			}
+			traverse(db.bins[db.coordsToIndex(i, j)], binDist)
+		}
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// AttachSized attaches a new object with a size to the database and returns a
+// proxy object.
+//
+// The object is a disc of the given radius, centered on (x,y). Its size is
+// taken into account by ForEachOverlapping. Other queries only consider the
+// object's center.
+func (db *DB[T]) AttachSized(t T, x, y, radius float64) *Proxy[T] {
+	db.mustNotBeFrozen("AttachSized")
+	obj := &Proxy[T]{object: t, layer: AllLayers, radius: radius, seq: db.nextSeq()}
+	db.Update(obj, x, y)
+	return obj
+}
+
+// addRadius records that an object of the given radius has been attached.
+func (db *DB[T]) addRadius(radius float64) {
+	if db.radii == nil {
+		db.radii = make(map[float64]int)
+	}
+	db.radii[radius]++
+	if radius > db.maxRadius {
+		db.maxRadius = radius
+	}
+}
+
+// removeRadius records that an object of the given radius has been detached.
+func (db *DB[T]) removeRadius(radius float64) {
+	db.radii[radius]--
+	if db.radii[radius] > 0 {
+		return
+	}
+	delete(db.radii, radius)
+	if radius == db.maxRadius {
+		// The largest object is gone, recompute the maximum here, so that
+		// queries never modify the database. The cost is proportional to the
+		// number of distinct radii.
+		db.maxRadius = 0
+		for r := range db.radii {
+			if r > db.maxRadius {
+				db.maxRadius = r
+			}
+		}
+	}
+}
+
+// ForEachOverlapping applies a user-supplied function to all objects
+// overlapping a certain locality.
+//
+// The locality is specified as a circle with a given center and radius. An
+// object overlaps the circle if its own disc (see AttachSized) intersects it,
+// objects without a size overlap the circle if their location is within it.
+// f is called with the squared distance from the center of the circle to the
+// center of each overlapping object. Since the center of a large object may be
+// far away from the circle, the search is extended by the radius of the
+// largest object currently in the database, that is by up to ⌈maxRadius/binSize⌉
+// bins in each direction.
+func (db *DB[T]) ForEachOverlapping(x, y, radius float64, f Func[T]) {
+	ext := radius + db.maxRadius
+	db.forEachCandidate(x-ext, y-ext, x+ext, y+ext, func(cp *Proxy[T]) {
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		r := radius + cp.radius
+		if sqDist < r*r {
+			f(cp.object, sqDist)
+		}
+	})
+}
+
+// QueryCursor records the progress of a resumable query, see
+// ForEachWithinRadiusResumable. The zero value starts a new query.
+type QueryCursor struct {
+	bin int // ordinal of the current bin, 0 being the "other" bin
+	pos int // number of objects of the current bin already tested
+}
+
+// ForEachWithinRadiusResumable is like ForEachWithinRadius, but f is applied to
+// at most budget objects, which allows time-slicing queries over large result
+// sets.
+//
+// The progress of the query is stored in cursor, so that the next call with
+// the same cursor and the same query parameters resumes from where the
+// previous one left off. The function returns true when the query is
+// complete, in which case cursor is reset and can be reused for a new query.
+// The database shouldn't be modified until the query completes, otherwise
+// some objects may be skipped, or processed twice. ForEachWithinRadiusResumable
+// panics if budget isn't positive.
+func (db *DB[T]) ForEachWithinRadiusResumable(x, y, radius float64, budget int, cursor *QueryCursor, f Func[T]) bool {
+	if budget <= 0 {
+		panic("lq: ForEachWithinRadiusResumable budget must be positive")
+	}
+
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	ny := maxBinY - minBinY + 1
+	nbins := (maxBinX - minBinX + 1) * ny
+
+	sqRadius := radius * radius
+	n := 0
+	for ; cursor.bin <= nbins; cursor.bin, cursor.pos = cursor.bin+1, 0 {
+		var cp *Proxy[T]
+		if cursor.bin == 0 {
+			if !outside {
+				continue
+			}
+			cp = db.other
+		} else {
+			k := cursor.bin - 1
+			cp = db.bins[db.coordsToIndex(minBinX+k/ny, minBinY+k%ny)]
+		}
+
+		// Skip the objects already tested.
+		for i := 0; i < cursor.pos && cp != nil; i++ {
+			cp = cp.next
+		}
+		for ; cp != nil; cp = cp.next {
+			sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+			if sqDist < sqRadius {
+				if n == budget {
+					return false
+				}
+				f(cp.object, sqDist)
+				n++
+			}
+			cursor.pos++
+		}
+	}
+
+	*cursor = QueryCursor{}
+	return true
+}
+
+// CentroidWithinRadius returns the centroid of the locations of the objects
+// within a certain locality, and the number of such objects.
+//
+// The locations are accumulated during the search, so nothing gets allocated.
+// If there is no object within radius, n is 0 and the centroid is (0,0).
+func (db *DB[T]) CentroidWithinRadius(x, y, radius float64) (cx, cy float64, n int) {
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if sqDist < sqRadius {
+			cx += cp.x
+			cy += cp.y
+			n++
+		}
+	})
+	if n == 0 {
+		return 0, 0, 0
+	}
+	return cx / float64(n), cy / float64(n), n
+}
+
+// HistogramWithinRadius counts the objects in concentric bands around (x,y).
+//
+// bandEdges holds the outer radius of each band, in ascending order. Element i
+// of the returned slice is the number of objects whose distance to (x,y) is in
+// [bandEdges[i-1], bandEdges[i]), the first band starting at 0. Objects beyond
+// the last edge aren't counted. All bands are filled in a single search.
+// HistogramWithinRadius panics if bandEdges isn't sorted.
+func (db *DB[T]) HistogramWithinRadius(x, y float64, bandEdges []float64) []int {
+	if !sort.Float64sAreSorted(bandEdges) {
+		panic("lq: HistogramWithinRadius bandEdges must be sorted")
+	}
+	counts := make([]int, len(bandEdges))
+	if len(bandEdges) == 0 {
+		return counts
+	}
+
+	radius := bandEdges[len(bandEdges)-1]
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if sqDist >= sqRadius {
+			return
+		}
+		band := sort.Search(len(bandEdges), func(i int) bool {
+			return sqDist < bandEdges[i]*bandEdges[i]
+		})
+		counts[band]++
+	})
+	return counts
+}
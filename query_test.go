@@ -0,0 +1,882 @@
+package lq
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestForEachWithinRadiusLayer(t *testing.T) {
+	const (
+		ground uint32 = 1 << iota
+		air
+		underground
+	)
+
+	var tests = []struct {
+		mask       uint32
+		r1, r2, r3 bool // expected result for the ground, air and underground objects
+		r4         bool // expected result for the object attached without layer
+	}{
+		{0, false, false, false, false},
+		{ground, true, false, false, true},
+		{air, false, true, false, true},
+		{underground, false, false, true, true},
+		{ground | air, true, true, false, true},
+		{ground | underground, true, false, true, true},
+		{AllLayers, true, true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("mask %03b", tt.mask), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+
+			db.AttachLayer(1, 5, 5, ground)
+			db.AttachLayer(2, 5, 6, air)
+			db.AttachLayer(3, 6, 5, underground)
+			db.Attach(4, 6, 6)
+
+			// Same layers but out of the search radius.
+			db.AttachLayer(5, 9, 9, ground)
+			db.AttachLayer(6, 9, 9, air)
+			db.AttachLayer(7, 9, 9, underground)
+
+			ids := make(idset)
+			db.ForEachWithinRadiusLayer(5, 5, 2, tt.mask, ids.storeID)
+
+			ids.assertIsContained(t, 1, tt.r1)
+			ids.assertIsContained(t, 2, tt.r2)
+			ids.assertIsContained(t, 3, tt.r3)
+			ids.assertIsContained(t, 4, tt.r4)
+			ids.assertNotContains(t, 5)
+			ids.assertNotContains(t, 6)
+			ids.assertNotContains(t, 7)
+		})
+	}
+}
+
+func TestForEachWithinRadiusMask(t *testing.T) {
+	db := NewDB[string](0, 0, 10, 10, 5, 5)
+	for i := 0; i < 8; i++ {
+		db.AttachIndexed("entity", i, 5+float64(i)/4, 5)
+	}
+	db.AttachIndexed("far", 8, 9, 9)
+	db.Attach("unindexed", 5, 5)
+
+	// Toggle off odd indices.
+	active := make([]bool, 9)
+	for i := range active {
+		active[i] = i%2 == 0
+	}
+
+	ids := make(idset)
+	db.ForEachWithinRadiusMask(5, 5, 2, func(index int) bool { return active[index] }, func(index int, sqDist float64) {
+		ids.storeID(index, sqDist)
+	})
+
+	for i := 0; i < 9; i++ {
+		ids.assertIsContained(t, i, i%2 == 0 && i < 8)
+	}
+	if len(ids) != 4 {
+		t.Errorf("got %d objects, want 4", len(ids))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("AttachIndexed with a negative index didn't panic")
+		}
+	}()
+	db.AttachIndexed("negative", -1, 5, 5)
+}
+
+func TestWithinRadiusPositions(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	pos := map[int][2]float64{
+		1: {4.5, 5},
+		2: {5.25, 5.75},
+		3: {6, 4},
+		4: {-0.5, 5}, // in the other bin
+		5: {9, 9},    // out of radius
+	}
+	for id := 1; id <= len(pos); id++ {
+		db.Attach(id, pos[id][0], pos[id][1])
+	}
+
+	got := db.WithinRadiusPositions(3, 5, 3.6)
+	if len(got) != 4 {
+		t.Fatalf("got %d objects, want 4: %v", len(got), got)
+	}
+	seen := make(map[int]bool)
+	for _, p := range got {
+		if p.Obj == 5 || seen[p.Obj] {
+			t.Errorf("unexpected object %d", p.Obj)
+		}
+		seen[p.Obj] = true
+		if want := pos[p.Obj]; p.X != want[0] || p.Y != want[1] {
+			t.Errorf("object %d at (%v, %v), want (%v, %v)", p.Obj, p.X, p.Y, want[0], want[1])
+		}
+	}
+
+	if got := db.WithinRadiusPositions(1, 9, 0.5); len(got) != 0 {
+		t.Errorf("got %v, want no objects", got)
+	}
+}
+
+func TestForEachWithinChebyshev(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	pos := make([][2]float64, 200)
+	for i := range pos {
+		pos[i] = [2]float64{rng.Float64()*12 - 1, rng.Float64()*12 - 1}
+		db.Attach(i, pos[i][0], pos[i][1])
+	}
+
+	for q := 0; q < 100; q++ {
+		x, y, dist := rng.Float64()*12-1, rng.Float64()*12-1, rng.Float64()*4
+		got := make(map[int]float64)
+		db.ForEachWithinChebyshev(x, y, dist, func(id int, d float64) {
+			got[id] = d
+		})
+
+		want := 0
+		for id, p := range pos {
+			d := math.Max(math.Abs(x-p[0]), math.Abs(y-p[1]))
+			if d >= dist {
+				if _, ok := got[id]; ok {
+					t.Fatalf("(%v, %v, %v): object %d at distance %v found", x, y, dist, id, d)
+				}
+				continue
+			}
+			want++
+			if gd, ok := got[id]; !ok || gd != d {
+				t.Fatalf("(%v, %v, %v): object %d at distance %v, got %v, %t", x, y, dist, id, d, gd, ok)
+			}
+		}
+		if len(got) != want {
+			t.Fatalf("(%v, %v, %v): got %d objects, want %d", x, y, dist, len(got), want)
+		}
+	}
+}
+
+func TestForEachWithinRadiusRingOrdered(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	// Bins of size 1x1.
+	db := NewDB[int](0, 0, 10, 10, 10, 10)
+	pos := make([][2]float64, 300)
+	for i := range pos {
+		pos[i] = [2]float64{rng.Float64()*12 - 1, rng.Float64()*12 - 1}
+		db.Attach(i, pos[i][0], pos[i][1])
+	}
+
+	for _, q := range [][3]float64{{5.5, 5.5, 3}, {0.2, 0.2, 4}, {9.9, 3, 2.5}, {-0.5, 5, 2}, {5, 5, 20}} {
+		want := make(idset)
+		db.ForEachWithinRadius(q[0], q[1], q[2], want.storeID)
+
+		cx, cy := clampBin(q[0], 10), clampBin(q[1], 10)
+		got := make(idset)
+		ring, inOther := 0, false
+		db.ForEachWithinRadiusRingOrdered(q[0], q[1], q[2], func(id int, sqDist float64) {
+			got.storeID(id, sqDist)
+			p := pos[id]
+			if p[0] < 0 || p[1] < 0 || p[0] >= 10 || p[1] >= 10 {
+				inOther = true
+				return
+			}
+			if inOther {
+				t.Errorf("%v: object %d visited after the other bin", q, id)
+			}
+			dx, dy := abs(int(p[0])-cx), abs(int(p[1])-cy)
+			r := dx
+			if dy > r {
+				r = dy
+			}
+			if r < ring {
+				t.Errorf("%v: object %d in ring %d visited after ring %d", q, id, r, ring)
+			}
+			ring = r
+		})
+
+		if len(got) != len(want) {
+			t.Errorf("%v: got %d objects, want %d", q, len(got), len(want))
+		}
+		for id := range want {
+			got.assertContains(t, id)
+		}
+	}
+}
+
+func TestForEachInBinNeighborhood(t *testing.T) {
+	// binID returns the ID of the object attached at the center of bin (i, j).
+	binID := func(i, j int) int { return 1 + i*5 + j }
+
+	var tests = []struct {
+		x, y      float64
+		ring      int
+		xmin      int // min and max coordinates of the bins that should be visited
+		ymin      int
+		xmax      int
+		ymax      int
+		wantOther bool
+	}{
+		{5, 5, 0, 2, 2, 2, 2, false},
+		{5, 5, 1, 1, 1, 3, 3, false},
+		{5, 5, 2, 0, 0, 4, 4, false},
+		{5, 5, 3, 0, 0, 4, 4, true},
+		{1, 1, 0, 0, 0, 0, 0, false},
+		{1, 1, 1, 0, 0, 1, 1, true},
+		{1, 1, 2, 0, 0, 2, 2, true},
+		{9, 3, 1, 3, 0, 4, 2, true},
+		{3, 5, 1, 0, 1, 2, 3, false},
+		{-1, -1, 0, 0, 0, 0, 0, true},
+		{11, 5, 1, 3, 1, 4, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("x=%v,y=%v,ring=%d", tt.x, tt.y, tt.ring), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			for i := 0; i < 5; i++ {
+				for j := 0; j < 5; j++ {
+					db.Attach(binID(i, j), float64(i)*2+1, float64(j)*2+1)
+				}
+			}
+			db.Attach(0, -5, -5)
+
+			ids := make(idset)
+			db.ForEachInBinNeighborhood(tt.x, tt.y, tt.ring, ids.storeID)
+
+			for i := 0; i < 5; i++ {
+				for j := 0; j < 5; j++ {
+					in := i >= tt.xmin && i <= tt.xmax && j >= tt.ymin && j <= tt.ymax
+					ids.assertIsContained(t, binID(i, j), in)
+				}
+			}
+			ids.assertIsContained(t, 0, tt.wantOther)
+		})
+	}
+}
+
+func TestSelectKInRadius(t *testing.T) {
+	greater := func(a, b int) bool { return a > b }
+
+	var tests = []struct {
+		k    int
+		r    float64
+		want []int
+	}{
+		{0, 3, nil},
+		{1, 3, []int{8}},
+		{3, 3, []int{8, 7, 5}},
+		{6, 3, []int{8, 7, 5, 4, 2, 1}},
+		{10, 3, []int{8, 7, 5, 4, 2, 1}},
+		{3, 100, []int{9, 8, 7}},
+		{3, 0.5, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("k=%d,r=%v", tt.k, tt.r), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 5, 5)
+			db.Attach(7, 5, 6)
+			db.Attach(2, 6, 5)
+			db.Attach(4, 4, 4)
+			db.Attach(8, 3, 5)
+			db.Attach(5, 5, 7.5)
+			db.Attach(3, 9, 9)  // out of radius 3
+			db.Attach(6, 0, 0)  // out of radius 3
+			db.Attach(9, -5, 5) // out of radius 3, outside the super-brick
+
+			got := db.SelectKInRadius(5, 5, tt.r, tt.k, greater)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForEachWithinRadiusBinDist(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 10, 10)
+	db.Attach(1, 5.5, 5.5)  // same bin as the query center
+	db.Attach(2, 6.5, 4.5)  // diagonal neighbor
+	db.Attach(3, 5.5, 7.5)  // 2 bins away
+	db.Attach(4, 2.2, 5.5)  // 3 bins away
+	db.Attach(5, 9.9, 9.9)  // edge bin
+	db.Attach(6, 10.5, 5.5) // outside of the super-brick
+	db.Attach(7, 0.5, 0.5)  // out of radius
+
+	want := map[int]int{
+		1: 0,
+		2: 1,
+		3: 2,
+		4: 3,
+		5: 4,
+		6: OtherBinDist,
+	}
+	got := make(map[int]int)
+	db.ForEachWithinRadiusBinDist(5.5, 5.5, 6.3, func(id int, _ float64, binDist int) {
+		got[id] = binDist
+	})
+	if len(got) != len(want) {
+		t.Errorf("got %d matches (%v), want %d", len(got), got, len(want))
+	}
+	for id, d := range want {
+		if got[id] != d {
+			t.Errorf("object %d: binDist = %d, want %d", id, got[id], d)
+		}
+	}
+}
+
+func TestForEachOverlapping(t *testing.T) {
+	var tests = []struct {
+		cx, cy, cr float64 // query circle
+		want       []int
+	}{
+		{5, 5, 0.1, []int{1}},
+		{5, 5, 1.6, []int{1, 2, 3}},
+		{5, 5, 2.5, []int{1, 2, 3, 4, 6}},
+		{6.5, 5, 0.1, []int{2}},
+		{2.6, 5, 0.5, []int{3}},
+		{5.5, 1.5, 0.6, []int{6}}, // 3 bins away from the center of object 6
+		{-2.9, 5, 0.5, []int{7}},  // object 7 is outside of the super-brick
+		{-1.9, 5, 0.5, []int{7}},
+		{9, 6, 0.5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("c=(%v,%v),r=%v", tt.cx, tt.cy, tt.cr), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 10, 10)
+			db.Attach(1, 5, 5)               // point
+			db.AttachSized(2, 7, 5, 1)       // overlaps x in [6, 8]
+			db.AttachSized(3, 3, 5, 0.5)     // overlaps x in [2.5, 3.5]
+			db.AttachSized(4, 5, 8, 0.6)     // overlaps y in [7.4, 8.6]
+			db.AttachSized(6, 8.5, 1.5, 2.5) // big object
+			db.AttachSized(7, -1.5, 5, 1)    // outside of the super-brick
+			db.AttachSized(8, 9, 9, 0.1)     // small object far away
+
+			ids := make(idset)
+			db.ForEachOverlapping(tt.cx, tt.cy, tt.cr, ids.storeID)
+
+			want := make(idset)
+			for _, id := range tt.want {
+				want[id] = struct{}{}
+			}
+			for id := 1; id <= 8; id++ {
+				_, ok := want[id]
+				ids.assertIsContained(t, id, ok)
+			}
+		})
+	}
+}
+
+func TestMaxObjectRadius(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 10, 10)
+	db.Attach(1, 5, 5)
+	huge := db.AttachSized(2, 9.5, 9.5, 11) // center far away from (2, 2)
+	p3 := db.AttachSized(3, 1, 1, 0.5)
+	db.AttachSized(4, 8, 1, 0.5)
+
+	ids := make(idset)
+	db.ForEachOverlapping(2, 2, 0.1, ids.storeID)
+	ids.assertContains(t, 2)
+
+	checkMax := func(want float64) {
+		t.Helper()
+		if got := db.maxRadius; got != want {
+			t.Errorf("maxRadius = %v, want %v", got, want)
+		}
+	}
+	checkMax(11)
+
+	db.Detach(huge)
+	checkMax(0.5)
+	ids = make(idset)
+	db.ForEachOverlapping(2, 2, 0.1, ids.storeID)
+	ids.assertNotContains(t, 2)
+
+	// Moving objects across bins doesn't change the max radius.
+	db.Update(p3, 4.5, 4.5)
+	checkMax(0.5)
+	db.Detach(p3)
+	checkMax(0.5)
+
+	db.DetachAll()
+	checkMax(0)
+	db.Update(huge, 1, 1)
+	checkMax(11)
+	db.Update(huge, 8, 8)
+	checkMax(11)
+	if n := db.radii[11]; n != 1 {
+		t.Errorf("radii[11] = %d after a move, want 1", n)
+	}
+}
+
+func TestForEachWithinRadiusState(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)
+	db.Attach(2, 6, 5)
+	db.Attach(3, 9, 9)    // out of radius
+	db.Attach(4, 10.5, 5) // outside of the super-brick
+
+	for _, c := range [][2]float64{{5, 5}, {9, 5}, {-3, -3}} {
+		want := make(idset)
+		db.ForEachWithinRadius(c[0], c[1], 2, want.storeID)
+
+		got := make(idset)
+		db.ForEachWithinRadiusState(c[0], c[1], 2, func(id int, _ float64, state any) {
+			state.(idset).storeID(id, 0)
+		}, got)
+
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("query at %v: got %v, want %v", c, got, want)
+		}
+	}
+}
+
+func TestForEachWithinRadiusProgress(t *testing.T) {
+	var tests = []struct {
+		x, y, radius float64
+		wantCalls    int
+		wantTotal    int
+	}{
+		{5.5, 5.5, 0.2, 1, 1},
+		{5, 5, 2, 5, 25},
+		{0.5, 5, 2, 3, 15}, // clipped to the super-brick
+		{5, 5, 20, 10, 100},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("x=%v,y=%v,r=%v", tt.x, tt.y, tt.radius), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 10, 10)
+			for i := 0; i < 10; i++ {
+				for j := 0; j < 10; j++ {
+					db.Attach(i*10+j, float64(i)+0.5, float64(j)+0.5)
+				}
+			}
+			db.Attach(100, -1, 5) // outside of the super-brick
+
+			calls := 0
+			lastDone := 0
+			got := make(idset)
+			db.ForEachWithinRadiusProgress(tt.x, tt.y, tt.radius, got.storeID, func(binsDone, binsTotal int) {
+				calls++
+				if binsTotal != tt.wantTotal {
+					t.Errorf("binsTotal = %d, want %d", binsTotal, tt.wantTotal)
+				}
+				if binsDone <= lastDone || binsDone > binsTotal {
+					t.Errorf("binsDone = %d after %d (binsTotal = %d)", binsDone, lastDone, binsTotal)
+				}
+				lastDone = binsDone
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("onBin called %d times, want %d", calls, tt.wantCalls)
+			}
+			if lastDone != tt.wantTotal {
+				t.Errorf("last binsDone = %d, want %d", lastDone, tt.wantTotal)
+			}
+
+			want := make(idset)
+			db.ForEachWithinRadius(tt.x, tt.y, tt.radius, want.storeID)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReduceWithinRadius(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)
+	db.Attach(2, 6, 5)
+	db.Attach(3, 9, 9)    // out of radius
+	db.Attach(4, 10.5, 5) // outside of the super-brick
+
+	type agg struct{ count, sum int }
+	add := func(acc agg, id int, _ float64) agg {
+		return agg{acc.count + 1, acc.sum + id}
+	}
+
+	var tests = []struct {
+		x, y, radius float64
+		want         agg
+	}{
+		{5, 5, 2, agg{2, 3}},
+		{7, 5, 4, agg{3, 7}},
+		{5, 5, 10, agg{4, 10}},
+		{0, 0, 1, agg{0, 0}},
+	}
+	for _, tt := range tests {
+		if got := ReduceWithinRadius(db, tt.x, tt.y, tt.radius, agg{}, add); got != tt.want {
+			t.Errorf("ReduceWithinRadius(%v, %v, %v) = %+v, want %+v", tt.x, tt.y, tt.radius, got, tt.want)
+		}
+	}
+
+	// The initial value is returned when no object is in range.
+	if got := ReduceWithinRadius(db, 0, 0, 1, -1.5, func(acc float64, _ int, sqDist float64) float64 {
+		return acc + sqDist
+	}); got != -1.5 {
+		t.Errorf("ReduceWithinRadius() = %v, want -1.5", got)
+	}
+}
+
+func TestForEachWithinRadiusFacing(t *testing.T) {
+	var tests = []struct {
+		fx, fy float64
+		want   []int
+	}{
+		{1, 0, []int{1, 2, 4, 6}},
+		{-1, 0, []int{1, 3, 4}},
+		{0, 1, []int{1, 2, 3}},
+		{0, -3, []int{1, 4, 6}},
+		{1, 1, []int{1, 2, 3, 6}},
+		{0, 0, []int{1, 2, 3, 4, 6}}, // no facing direction, everything is in front
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("facing=(%v,%v)", tt.fx, tt.fy), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 5, 5)     // at the center, always in front
+			db.Attach(2, 6, 6)     // north-east
+			db.Attach(3, 4, 6)     // north-west
+			db.Attach(4, 5, 4)     // south, on the border of the east/west half-planes
+			db.Attach(5, 9, 5)     // east, out of radius
+			db.Attach(6, 6.5, 4.5) // east-southeast
+
+			ids := make(idset)
+			db.ForEachWithinRadiusFacing(5, 5, 2, tt.fx, tt.fy, ids.storeID)
+
+			want := make(idset)
+			for _, id := range tt.want {
+				want[id] = struct{}{}
+			}
+			for id := 1; id <= 6; id++ {
+				_, ok := want[id]
+				ids.assertIsContained(t, id, ok)
+			}
+		})
+	}
+}
+
+func TestForEachWithinRadiusExceptRect(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)     // inside the hole
+	db.Attach(2, 6, 5)     // on the hole edge
+	db.Attach(3, 4.5, 6.5) // within radius, above the hole
+	db.Attach(4, 3, 5)     // within radius, left of the hole
+	db.Attach(5, 8, 8)     // out of radius
+	db.Attach(6, 7.5, 4)   // within radius, right of the hole
+	db.Attach(7, -1, 5)    // other bin, out of radius
+
+	ids := make(idset)
+	db.ForEachWithinRadiusExceptRect(5, 5, 3, 4, 4, 6, 6, ids.storeID)
+
+	ids.assertNotContains(t, 1)
+	ids.assertNotContains(t, 2)
+	ids.assertContains(t, 3)
+	ids.assertContains(t, 4)
+	ids.assertNotContains(t, 5)
+	ids.assertContains(t, 6)
+	ids.assertNotContains(t, 7)
+
+	// An empty hole doesn't exclude anything.
+	ids = make(idset)
+	db.ForEachWithinRadiusExceptRect(5, 5, 3, 1, 1, 0, 0, ids.storeID)
+	for _, id := range []int{1, 2, 3, 4, 6} {
+		ids.assertContains(t, id)
+	}
+}
+
+func TestForEachWithinRectExceptCircle(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)     // in the keep-out circle
+	db.Attach(2, 5.5, 5.5) // in the keep-out circle
+	db.Attach(3, 6, 5)     // on the circle
+	db.Attach(4, 2, 3)     // in the rectangle
+	db.Attach(5, 8, 7)     // on the rectangle corner
+	db.Attach(6, 8.5, 5)   // right of the rectangle
+	db.Attach(7, 5, 1.5)   // below the rectangle
+	db.Attach(8, -1, 5)    // other bin, left of the rectangle
+
+	ids := make(idset)
+	db.ForEachWithinRectExceptCircle(2, 2, 8, 7, 5, 5, 1, ids.storeID)
+
+	ids.assertNotContains(t, 1)
+	ids.assertNotContains(t, 2)
+	ids.assertContains(t, 3)
+	ids.assertContains(t, 4)
+	ids.assertContains(t, 5)
+	ids.assertNotContains(t, 6)
+	ids.assertNotContains(t, 7)
+	ids.assertNotContains(t, 8)
+
+	// The rectangle extends outside of the super-brick.
+	ids = make(idset)
+	db.ForEachWithinRectExceptCircle(-2, 4, 3, 6, 5, 5, 1, ids.storeID)
+	ids.assertContains(t, 8)
+	ids.assertNotContains(t, 4)
+	if len(ids) != 1 {
+		t.Errorf("got %d objects, want 1", len(ids))
+	}
+}
+
+func TestForEachWithinRadiusMutate(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	p1 := db.Attach(1, 4.9, 5)
+	p2 := db.Attach(2, 5.1, 5)
+	db.Attach(3, 9, 9) // out of radius
+
+	// Push the objects away from (5,5), so that they end up 2 units apart, in
+	// other bins.
+	visits := make(map[int]int)
+	db.ForEachWithinRadiusMutate(5, 5, 1, func(p *Proxy[int], sqDist float64) (float64, float64, bool) {
+		visits[p.Object()]++
+		x, y := p.Location()
+		if x < 5 {
+			return 4, y, true
+		}
+		return 6, y, true
+	})
+
+	if len(visits) != 2 || visits[1] != 1 || visits[2] != 1 {
+		t.Errorf("visits = %v, want objects 1 and 2 visited once", visits)
+	}
+	if x, y := p1.Location(); x != 4 || y != 5 {
+		t.Errorf("p1.Location() = (%v,%v), want (4,5)", x, y)
+	}
+	if x, y := p2.Location(); x != 6 || y != 5 {
+		t.Errorf("p2.Location() = (%v,%v), want (6,5)", x, y)
+	}
+
+	// The objects have been re-binned.
+	ids := make(idset)
+	db.ForEachWithinRadius(4, 5, 0.5, ids.storeID)
+	ids.assertContains(t, 1)
+	ids.assertNotContains(t, 2)
+	ids = make(idset)
+	db.ForEachWithinRadius(6, 5, 0.5, ids.storeID)
+	ids.assertContains(t, 2)
+	ids.assertNotContains(t, 1)
+}
+
+func TestRadiusEmpty(t *testing.T) {
+	var tests = []struct {
+		x, y, radius float64
+		want         bool
+	}{
+		{5, 5, 0.5, false},
+		{5, 5, 3, false},
+		{2, 8, 1, true},
+		{2, 8, 2.9, true},
+		{2, 8, 3.1, false},
+		{9, 9, 0.5, true},
+		{11.5, 5, 1, false}, // object outside of the super-brick
+		{-3, -3, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("x=%v,y=%v,r=%v", tt.x, tt.y, tt.radius), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 5, 5)
+			db.Attach(2, 5, 8)
+			db.Attach(3, 12, 5)
+
+			if got := db.RadiusEmpty(tt.x, tt.y, tt.radius); got != tt.want {
+				t.Errorf("RadiusEmpty() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+
+	if db := NewDB[int](0, 0, 10, 10, 5, 5); !db.RadiusEmpty(5, 5, 100) {
+		t.Errorf("empty database: RadiusEmpty() = false, want true")
+	}
+}
+
+func TestWithinRadiusByBin(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)      // bin (2,2)
+	db.Attach(2, 5.5, 5.5)  // bin (2,2)
+	db.Attach(3, 6.5, 5)    // bin (3,2)
+	db.Attach(4, 5, 3.9)    // bin (2,1)
+	db.Attach(5, 9, 9)      // out of radius
+	db.Attach(6, 10.5, 5.5) // outside of the super-brick
+	db.Attach(7, 3.5, 7.5)  // out of radius, in a visited bin
+
+	got := db.WithinRadiusByBin(7, 5, 3.6)
+	want := map[[2]int][]int{
+		{2, 2}:      {2, 1},
+		{3, 2}:      {3},
+		{2, 1}:      {4},
+		OtherBinKey: {6},
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d bins (%v), want %d", len(got), got, len(want))
+	}
+	for key, ids := range want {
+		if fmt.Sprint(got[key]) != fmt.Sprint(ids) {
+			t.Errorf("bin %v: got %v, want %v", key, got[key], ids)
+		}
+	}
+
+	if got := db.WithinRadiusByBin(1, 1, 1); len(got) != 0 {
+		t.Errorf("empty locality: got %v, want no bins", got)
+	}
+}
+
+func TestForEachWithinOBB(t *testing.T) {
+	// boxPoint returns the global coordinates of the point at (lx,ly) in the
+	// frame of a box centered on (5,5) and rotated by 45°.
+	boxPoint := func(lx, ly float64) (float64, float64) {
+		sin, cos := math.Sincos(math.Pi / 4)
+		return 5 + lx*cos - ly*sin, 5 + lx*sin + ly*cos
+	}
+
+	var tests = []struct {
+		lx, ly float64 // location in the box frame
+		want   bool
+	}{
+		{0, 0, true},
+		{1.9, 0, true},
+		{2.1, 0, false},
+		{-1.9, 0, true},
+		{-2.1, 0, false},
+		{0, 0.9, true},
+		{0, 1.1, false},
+		{0, -0.9, true},
+		{0, -1.1, false},
+		{1.9, 0.9, true},   // near a corner
+		{1.9, 1.1, false},  // past a long edge
+		{2.1, 0.9, false},  // past a short edge
+		{-1.9, -0.9, true}, // near the opposite corner
+		{2.8, 0, false},    // in the AABB, outside of the box
+	}
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	for i, tt := range tests {
+		x, y := boxPoint(tt.lx, tt.ly)
+		db.Attach(i, x, y)
+	}
+	db.Attach(-1, 7, 7) // corner of the AABB
+
+	ids := make(idset)
+	db.ForEachWithinOBB(5, 5, 2, 1, math.Pi/4, ids.storeID)
+	ids.assertNotContains(t, -1)
+	for i, tt := range tests {
+		if _, ok := ids[i]; ok != tt.want {
+			t.Errorf("point (%v,%v) in the box frame: found = %t, want %t", tt.lx, tt.ly, ok, tt.want)
+		}
+	}
+
+	// Without rotation, the box is axis-aligned.
+	ids = make(idset)
+	db.ForEachWithinOBB(7, 7, 0.1, 0.1, 0, ids.storeID)
+	ids.assertContains(t, -1)
+}
+
+func TestForEachWithinRadiusResumable(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	id := 0
+	for x := -1.0; x < 11; x += 0.25 {
+		for y := -1.0; y < 11; y += 0.25 {
+			db.Attach(id, x, y)
+			id++
+		}
+	}
+
+	want := make(idset)
+	db.ForEachWithinRadius(5, 5, 5.5, want.storeID)
+
+	for _, budget := range []int{1, 7, 100, len(want), len(want) + 1} {
+		t.Run(fmt.Sprintf("budget %d", budget), func(t *testing.T) {
+			var cursor QueryCursor
+			got := make(map[int]int)
+			calls := 0
+			for done := false; !done; {
+				if calls > len(want) {
+					t.Fatalf("query not complete after %d calls", calls)
+				}
+				n := 0
+				done = db.ForEachWithinRadiusResumable(5, 5, 5.5, budget, &cursor, func(id int, _ float64) {
+					got[id]++
+					n++
+				})
+				if n > budget {
+					t.Fatalf("call %d processed %d objects, budget is %d", calls, n, budget)
+				}
+				calls++
+			}
+
+			if len(got) != len(want) {
+				t.Errorf("got %d objects, want %d", len(got), len(want))
+			}
+			for id, n := range got {
+				if n != 1 {
+					t.Errorf("object %d processed %d times", id, n)
+				}
+				want.assertContains(t, id)
+			}
+			if wantCalls := (len(want) + budget - 1) / budget; calls < wantCalls || calls > wantCalls+1 {
+				t.Errorf("query completed in %d calls, want %d", calls, wantCalls)
+			}
+			if cursor != (QueryCursor{}) {
+				t.Errorf("cursor not reset after completion: %+v", cursor)
+			}
+		})
+	}
+}
+
+func TestCentroidWithinRadius(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 4, 4)
+	db.Attach(2, 6, 4)
+	db.Attach(3, 6, 7)
+	db.Attach(4, 4, 7)
+	db.Attach(5, 9.5, 9.5)
+	db.Attach(6, 11, 9.5) // in the other bin
+
+	var tests = []struct {
+		x, y, r float64
+		cx, cy  float64
+		n       int
+	}{
+		{5, 5, 3, 5, 5.5, 4},
+		{4, 4, 1, 4, 4, 1},
+		{5, 4, 1.5, 5, 4, 2},
+		{10, 9.5, 2, 10.25, 9.5, 2},
+		{1, 9, 1, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		cx, cy, n := db.CentroidWithinRadius(tt.x, tt.y, tt.r)
+		if n != tt.n || math.Abs(cx-tt.cx) > 1e-12 || math.Abs(cy-tt.cy) > 1e-12 {
+			t.Errorf("CentroidWithinRadius(%v, %v, %v) = (%v, %v, %d), want (%v, %v, %d)",
+				tt.x, tt.y, tt.r, cx, cy, n, tt.cx, tt.cy, tt.n)
+		}
+	}
+}
+
+func TestHistogramWithinRadius(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)     // distance 0
+	db.Attach(2, 5.5, 5)   // distance 0.5
+	db.Attach(3, 6, 5)     // distance 1, on the first edge
+	db.Attach(4, 5, 3.5)   // distance 1.5
+	db.Attach(5, 7.5, 5)   // distance 2.5
+	db.Attach(6, 5, 8)     // distance 3, on the last edge
+	db.Attach(7, -0.5, 1)  // in the other bin
+	db.Attach(8, 9.5, 9.5) // far away
+
+	var tests = []struct {
+		x, y  float64
+		edges []float64
+		want  []int
+	}{
+		{5, 5, []float64{1, 2, 3}, []int{2, 2, 1}},
+		{5, 5, []float64{3}, []int{5}},
+		{5, 5, []float64{0.5, 0.5, 2}, []int{1, 0, 3}},
+		{5, 5, nil, []int{}},
+		{1, 1, []float64{1, 2}, []int{0, 1}},
+	}
+	for _, tt := range tests {
+		got := db.HistogramWithinRadius(tt.x, tt.y, tt.edges)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("HistogramWithinRadius(%v, %v, %v) = %v, want %v", tt.x, tt.y, tt.edges, got, tt.want)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("HistogramWithinRadius with unsorted edges didn't panic")
+		}
+	}()
+	db.HistogramWithinRadius(5, 5, []float64{2, 1})
+}
@@ -0,0 +1,84 @@
+package lq
+
+// ReadOnlyDB is a read-only view of a database.
+//
+// It only provides query methods, so that subsystems which should never modify
+// the database can't do it by accident: this is enforced at compile time. The
+// view reflects the modifications made to the underlying database.
+type ReadOnlyDB[T comparable] struct {
+	db *DB[T]
+}
+
+// ReadOnly returns a read-only view of the database.
+func (db *DB[T]) ReadOnly() ReadOnlyDB[T] {
+	return ReadOnlyDB[T]{db: db}
+}
+
+// Len returns the number of objects in the database.
+func (ro ReadOnlyDB[T]) Len() int {
+	return ro.db.Len()
+}
+
+// OtherCount returns the number of objects in the "other" bin.
+func (ro ReadOnlyDB[T]) OtherCount() int {
+	return ro.db.OtherCount()
+}
+
+// BinSize returns the size of a bin in world units (see DB.BinSize).
+func (ro ReadOnlyDB[T]) BinSize() (w, h float64) {
+	return ro.db.BinSize()
+}
+
+// ForEachObject applies f to all objects in the database (see
+// DB.ForEachObject).
+func (ro ReadOnlyDB[T]) ForEachObject(f Func[T]) {
+	ro.db.ForEachObject(f)
+}
+
+// ForEachWithinRadius applies f to all objects in a certain locality (see
+// DB.ForEachWithinRadius).
+func (ro ReadOnlyDB[T]) ForEachWithinRadius(x, y, radius float64, f Func[T]) {
+	ro.db.ForEachWithinRadius(x, y, radius, f)
+}
+
+// ForEachWithinRadiusLayer is like ForEachWithinRadius but only considers the
+// objects belonging to the layers in mask (see DB.ForEachWithinRadiusLayer).
+func (ro ReadOnlyDB[T]) ForEachWithinRadiusLayer(x, y, radius float64, mask uint32, f Func[T]) {
+	ro.db.ForEachWithinRadiusLayer(x, y, radius, mask, f)
+}
+
+// ForEachWithinOBB applies f to all objects within an oriented rectangle (see
+// DB.ForEachWithinOBB).
+func (ro ReadOnlyDB[T]) ForEachWithinOBB(cx, cy, halfW, halfH, angle float64, f Func[T]) {
+	ro.db.ForEachWithinOBB(cx, cy, halfW, halfH, angle, f)
+}
+
+// ForEachOverlapping applies f to all objects overlapping a circle (see
+// DB.ForEachOverlapping).
+func (ro ReadOnlyDB[T]) ForEachOverlapping(x, y, radius float64, f Func[T]) {
+	ro.db.ForEachOverlapping(x, y, radius, f)
+}
+
+// RadiusEmpty reports whether there is no object within a circle (see
+// DB.RadiusEmpty).
+func (ro ReadOnlyDB[T]) RadiusEmpty(x, y, radius float64) bool {
+	return ro.db.RadiusEmpty(x, y, radius)
+}
+
+// FindNearestInRadius finds the object nearest to a location yet within a
+// radius (see DB.FindNearestInRadius).
+func (ro ReadOnlyDB[T]) FindNearestInRadius(x, y, radius float64, ignored T) (T, bool) {
+	return ro.db.FindNearestInRadius(x, y, radius, ignored)
+}
+
+// FindTwoNearestInRadius finds the two objects nearest to a location yet
+// within a radius (see DB.FindTwoNearestInRadius).
+func (ro ReadOnlyDB[T]) FindTwoNearestInRadius(x, y, radius float64, ignored T) (first, second T, foundFirst, foundSecond bool) {
+	return ro.db.FindTwoNearestInRadius(x, y, radius, ignored)
+}
+
+// FindKNearestReport finds the k objects nearest to a location yet within a
+// radius (see DB.FindKNearestReport).
+func (ro ReadOnlyDB[T]) FindKNearestReport(x, y, radius float64, k int, ignored T) (neighbors []Neighbor[T], moreAvailable bool) {
+	return ro.db.FindKNearestReport(x, y, radius, k, ignored)
+}
@@ -0,0 +1,55 @@
+package lq
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 5, 5)
+	p := db.Attach(2, 6, 5)
+	db.Attach(3, 20, 20)
+
+	ro := db.ReadOnly()
+	if ro.Len() != 3 || ro.OtherCount() != 1 {
+		t.Errorf("Len() = %d, OtherCount() = %d, want 3, 1", ro.Len(), ro.OtherCount())
+	}
+	ids := make(idset)
+	ro.ForEachWithinRadius(5, 5, 2, ids.storeID)
+	ids.assertContains(t, 1)
+	ids.assertContains(t, 2)
+	ids.assertNotContains(t, 3)
+
+	// The view reflects the modifications of the database.
+	db.Update(p, 4.5, 5)
+	if got, ok := ro.FindNearestInRadius(4, 5, 2, 0); !ok || got != 2 {
+		t.Errorf("FindNearestInRadius() = %v, %t, want 2", got, ok)
+	}
+
+	// The view doesn't provide any method modifying the database.
+	typ := reflect.TypeOf(ro)
+	for _, name := range []string{
+		"Attach", "AttachChecked", "AttachLayer", "AttachIndexed", "AttachSized",
+		"Update", "UpdateChecked", "Reattach", "Migrate",
+		"Detach", "DetachReport", "DetachAll", "Walk",
+		"Freeze", "Unfreeze", "Recenter", "Regrid", "SetEquals",
+	} {
+		if _, ok := typ.MethodByName(name); ok {
+			t.Errorf("ReadOnlyDB has method %s", name)
+		}
+	}
+	dbTyp := reflect.TypeOf(db)
+	for i := 0; i < typ.NumMethod(); i++ {
+		m := typ.Method(i)
+		dm, ok := dbTyp.MethodByName(m.Name)
+		if !ok {
+			t.Errorf("ReadOnlyDB.%s has no DB counterpart", m.Name)
+			continue
+		}
+		// Compare signatures, without receivers.
+		if m.Type.NumIn() != dm.Type.NumIn() || m.Type.NumOut() != dm.Type.NumOut() {
+			t.Errorf("ReadOnlyDB.%s signature differs from DB.%s", m.Name, m.Name)
+		}
+	}
+}
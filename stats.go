@@ -0,0 +1,230 @@
+package lq
+
+// QueryStats holds counters describing the work performed by the queries run
+// on a database, see DB.EnableStats.
+type QueryStats struct {
+	Queries       int // number of queries run
+	BinsVisited   int // number of bins visited, including the "other" bin
+	ObjectsTested int // number of objects whose distance has been tested
+	Matches       int // number of objects passed to the query callbacks
+}
+
+// EnableStats enables the collection of query statistics, that can then be
+// retrieved with Stats.
+//
+// Statistics are collected by ForEachWithinRadius and the queries built on top
+// of it, such as FindNearestInRadius. Comparing the number of objects tested
+// to the number of matches tells whether the grid resolution is adapted to the
+// typical query radius. Statistics are disabled by default since they slightly
+// slow down queries. Calling EnableStats resets the counters.
+func (db *DB[T]) EnableStats() {
+	db.stats = &QueryStats{}
+}
+
+// DisableStats disables the collection of query statistics.
+func (db *DB[T]) DisableStats() {
+	db.stats = nil
+}
+
+// Stats returns the query statistics collected since EnableStats has been
+// called, or the zero QueryStats if statistics are disabled.
+func (db *DB[T]) Stats() QueryStats {
+	if db.stats == nil {
+		return QueryStats{}
+	}
+	return *db.stats
+}
+
+// forEachWithinRadiusStats is the instrumented version of ForEachWithinRadius,
+// used when statistics are enabled.
+func (db *DB[T]) forEachWithinRadiusStats(x, y, radius float64, f Func[T]) {
+	st := db.stats
+	st.Queries++
+
+	sqRadius := radius * radius
+	visit := func(cp *Proxy[T]) {
+		st.BinsVisited++
+		for ; cp != nil; cp = cp.next {
+			st.ObjectsTested++
+			sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+			if sqDist < sqRadius {
+				st.Matches++
+				f(cp.object, sqDist)
+			}
+		}
+	}
+
+	minBinX, minBinY, maxBinX, maxBinY, outside := db.binRange(x-radius, y-radius, x+radius, y+radius)
+	if outside {
+		visit(db.other)
+	}
+	for i := minBinX; i <= maxBinX; i++ {
+		for j := minBinY; j <= maxBinY; j++ {
+			visit(db.bins[db.coordsToIndex(i, j)])
+		}
+	}
+}
+
+// ForEachWithinRadiusMeasured is like ForEachWithinRadius but it also returns
+// the number of objects whose distance has been tested, and the number of
+// objects passed to f.
+//
+// Contrary to EnableStats, this measures a single query, which is handy to
+// compute the selectivity of a grid configuration. The query statistics, if
+// enabled, are not updated.
+func (db *DB[T]) ForEachWithinRadiusMeasured(x, y, radius float64, f Func[T]) (tested, matched int) {
+	sqRadius := radius * radius
+	db.forEachCandidate(x-radius, y-radius, x+radius, y+radius, func(cp *Proxy[T]) {
+		tested++
+		sqDist := (x-cp.x)*(x-cp.x) + (y-cp.y)*(y-cp.y)
+		if sqDist < sqRadius {
+			matched++
+			f(cp.object, sqDist)
+		}
+	})
+	return tested, matched
+}
+
+// SetOtherThreshold sets a callback invoked when the fraction of objects that
+// are outside of the super-brick exceeds frac.
+//
+// cb is called with the number of objects in the "other" bin and the total
+// number of objects. It's invoked by the method which made the fraction exceed
+// frac (Attach, Update, etc.), then it won't be invoked again until the
+// fraction has fallen back at or below frac. This tells the application to
+// enlarge or move the super-brick. Passing a nil callback removes the
+// threshold.
+func (db *DB[T]) SetOtherThreshold(frac float64, cb func(otherCount, total int)) {
+	db.otherCb = cb
+	db.otherFrac = frac
+	db.otherAlerted = false
+	db.checkOtherThreshold()
+}
+
+// checkOtherThreshold invokes the "other" threshold callback if necessary.
+func (db *DB[T]) checkOtherThreshold() {
+	if db.otherCb == nil {
+		return
+	}
+
+	exceeded := float64(db.nother) > db.otherFrac*float64(db.n)
+	if exceeded && !db.otherAlerted {
+		db.otherAlerted = true
+		db.otherCb(db.nother, db.n)
+	} else if !exceeded {
+		db.otherAlerted = false
+	}
+}
+
+// BinStats describes the occupancy of the bins of a database, the "other" bin
+// aside.
+type BinStats struct {
+	Bins     int     // number of bins
+	Objects  int     // number of objects in the bins
+	Mean     float64 // mean number of objects per bin
+	Variance float64 // variance of the number of objects per bin
+}
+
+// newBinStats returns the BinStats of a database, given the sum of the squares
+// of the number of objects in each bin.
+func (db *DB[T]) newBinStats(sumSq int) BinStats {
+	st := BinStats{Bins: len(db.bins), Objects: db.n - db.nother}
+	st.Mean = float64(st.Objects) / float64(st.Bins)
+	st.Variance = float64(sumSq)/float64(st.Bins) - st.Mean*st.Mean
+	return st
+}
+
+// binLen returns the number of objects in a bin.
+func binLen[T any](head *Proxy[T]) int {
+	n := 0
+	for cp := head; cp != nil; cp = cp.next {
+		n++
+	}
+	return n
+}
+
+// binSumSq returns the sum of the squares of the number of objects in each bin.
+func (db *DB[T]) binSumSq() int {
+	sumSq := 0
+	for _, head := range db.bins {
+		c := binLen(head)
+		sumSq += c * c
+	}
+	return sumSq
+}
+
+// BinStats computes the occupancy statistics of the bins.
+//
+// Its cost is proportional to the number of bins plus the number of objects,
+// see EnableLiveStats for constant time statistics.
+func (db *DB[T]) BinStats() BinStats {
+	return db.newBinStats(db.binSumSq())
+}
+
+// EnableLiveStats enables the maintenance of running bin statistics, that can
+// then be retrieved in constant time with LiveStats.
+//
+// Live statistics are updated in constant time each time an object changes
+// bin, but they require keeping the number of objects of each bin, which is
+// why they are disabled by default. EnableLiveStats itself counts the objects
+// of every bin.
+func (db *DB[T]) EnableLiveStats() {
+	db.liveCounts = make([]int, len(db.bins))
+	db.liveSumSq = 0
+	for i, head := range db.bins {
+		c := binLen(head)
+		db.liveCounts[i] = c
+		db.liveSumSq += c * c
+	}
+}
+
+// DisableLiveStats disables the maintenance of running bin statistics.
+func (db *DB[T]) DisableLiveStats() {
+	db.liveCounts = nil
+	db.liveSumSq = 0
+}
+
+// LiveStats returns the same statistics as BinStats, in constant time, or the
+// zero BinStats if live statistics are disabled.
+func (db *DB[T]) LiveStats() BinStats {
+	if db.liveCounts == nil {
+		return BinStats{}
+	}
+	return db.newBinStats(db.liveSumSq)
+}
+
+// BinCount is the number of objects in the bin of coordinates (Ix,Iy).
+type BinCount struct {
+	Ix, Iy int
+	Count  int
+}
+
+// TopBins returns the n most populated bins, the "other" bin aside, sorted by
+// decreasing number of objects, then by increasing coordinates.
+//
+// Empty bins are never returned, so there may be less than n bins. This
+// reveals the hotspots slowing down the queries, where objects are clustered.
+// The cost of TopBins is proportional to the number of bins plus the number of
+// objects.
+func (db *DB[T]) TopBins(n int) []BinCount {
+	if n <= 0 {
+		return nil
+	}
+	h := newBoundedHeap(n, func(a, b BinCount) bool {
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Ix != b.Ix {
+			return a.Ix < b.Ix
+		}
+		return a.Iy < b.Iy
+	})
+	for i := 0; i < db.xdiv; i++ {
+		for j := 0; j < db.ydiv; j++ {
+			if c := binLen(db.bins[db.coordsToIndex(i, j)]); c > 0 {
+				h.push(BinCount{Ix: i, Iy: j, Count: c})
+			}
+		}
+	}
+	return h.sorted()
+}
@@ -0,0 +1,233 @@
+package lq
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestQueryStats(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	db.Attach(1, 1, 1)
+	db.Attach(2, 1.5, 1.5)
+	db.Attach(3, 3, 1)
+	db.Attach(4, 9, 9)
+	db.Attach(5, -1, -1)
+
+	// Queries run before stats are enabled aren't counted.
+	db.ForEachWithinRadius(1, 1, 1, func(int, float64) {})
+	if got := db.Stats(); got != (QueryStats{}) {
+		t.Fatalf("Stats() = %+v, want zero stats when disabled", got)
+	}
+
+	db.EnableStats()
+
+	// Bin (0,0) only, tests objects 1 and 2, both match.
+	db.ForEachWithinRadius(1, 1, 0.9, func(int, float64) {})
+	// Bins (0,0) to (1,1), tests objects 1, 2 and 3, all match.
+	db.ForEachWithinRadius(2, 2, 1.9, func(int, float64) {})
+	// Bin (0,0) plus "other", tests objects 1, 2 and 5, only 5 matches.
+	db.FindNearestInRadius(-1.5, -1.5, 2, 0)
+
+	want := QueryStats{
+		Queries:       3,
+		BinsVisited:   1 + 4 + 2,
+		ObjectsTested: 2 + 3 + 3,
+		Matches:       2 + 3 + 1,
+	}
+	if got := db.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	db.DisableStats()
+	db.ForEachWithinRadius(1, 1, 1, func(int, float64) {})
+	if got := db.Stats(); got != (QueryStats{}) {
+		t.Errorf("Stats() = %+v, want zero stats when disabled", got)
+	}
+}
+
+func TestForEachWithinRadiusMeasured(t *testing.T) {
+	var tests = []struct {
+		x, y, radius          float64
+		wantTested, wantMatch int
+	}{
+		{1, 1, 0.9, 2, 2},     // bin (0,0) only
+		{2, 2, 1.9, 3, 3},     // bins (0,0) to (1,1)
+		{3, 3, 0.5, 0, 0},     // bin (1,1), empty
+		{-1.5, -1.5, 2, 3, 1}, // bin (0,0) plus "other"
+		{5, 5, 10, 5, 5},      // everything
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("x=%v,y=%v,r=%v", tt.x, tt.y, tt.radius), func(t *testing.T) {
+			db := NewDB[int](0, 0, 10, 10, 5, 5)
+			db.Attach(1, 1, 1)
+			db.Attach(2, 1.5, 1.5)
+			db.Attach(3, 3, 1)
+			db.Attach(4, 9, 9)
+			db.Attach(5, -1, -1)
+			db.EnableStats()
+
+			n := 0
+			tested, matched := db.ForEachWithinRadiusMeasured(tt.x, tt.y, tt.radius, func(int, float64) { n++ })
+			if tested != tt.wantTested || matched != tt.wantMatch {
+				t.Errorf("tested, matched = %d, %d, want %d, %d", tested, matched, tt.wantTested, tt.wantMatch)
+			}
+			if n != matched {
+				t.Errorf("f called %d times, want %d", n, matched)
+			}
+			if got := db.Stats(); got != (QueryStats{}) {
+				t.Errorf("Stats() = %+v, want zero stats", got)
+			}
+		})
+	}
+}
+
+func TestSetOtherThreshold(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+
+	var calls [][2]int
+	db.SetOtherThreshold(0.5, func(otherCount, total int) {
+		calls = append(calls, [2]int{otherCount, total})
+	})
+	assertCalls := func(want ...[2]int) {
+		t.Helper()
+		if fmt.Sprint(calls) != fmt.Sprint(want) {
+			t.Errorf("callback calls = %v, want %v", calls, want)
+		}
+	}
+
+	db.Attach(1, 1, 1)
+	db.Attach(2, 2, 2)
+	db.Attach(3, 3, 3)
+	db.Attach(4, -1, -1)
+	p5 := db.Attach(5, -2, -2)
+	assertCalls() // 2/5
+
+	p6 := db.Attach(6, -3, -3) // 3/6
+	assertCalls()
+
+	db.Attach(7, -4, -4) // 4/7
+	assertCalls([2]int{4, 7})
+
+	// The callback isn't invoked again while the fraction stays above the
+	// threshold.
+	db.Attach(8, -5, -5) // 5/8
+	assertCalls([2]int{4, 7})
+
+	db.Update(p5, 5, 5) // 4/8
+	db.Detach(p6)       // 3/7
+	assertCalls([2]int{4, 7})
+
+	db.Update(p5, 11, 11) // 4/7
+	assertCalls([2]int{4, 7}, [2]int{4, 7})
+
+	db.SetOtherThreshold(0, nil)
+	db.Attach(9, -6, -6)
+	assertCalls([2]int{4, 7}, [2]int{4, 7})
+}
+
+func TestBinStats(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 2, 2)
+	db.Attach(1, 1, 1)
+	db.Attach(2, 2, 2)
+	db.Attach(3, 3, 3)
+	db.Attach(4, 7, 7)
+	db.Attach(5, 20, 20) // "other" bin
+
+	// Occupancy is 3, 0, 0, 1.
+	want := BinStats{Bins: 4, Objects: 4, Mean: 1, Variance: 1.5}
+	if got := db.BinStats(); got != want {
+		t.Errorf("BinStats() = %+v, want %+v", got, want)
+	}
+	if got := db.LiveStats(); got != (BinStats{}) {
+		t.Errorf("LiveStats() = %+v before EnableLiveStats, want zero", got)
+	}
+}
+
+func TestLiveStats(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	db := NewDB[int](0, 0, 10, 10, 4, 4)
+	var proxies []*Proxy[int]
+	attach := func() {
+		// Some objects fall in the "other" bin.
+		p := db.Attach(len(proxies), rng.Float64()*12-1, rng.Float64()*12-1)
+		proxies = append(proxies, p)
+	}
+	for i := 0; i < 50; i++ {
+		attach()
+	}
+
+	db.EnableLiveStats()
+	check := func(step string) {
+		t.Helper()
+		live, want := db.LiveStats(), db.BinStats()
+		if live.Bins != want.Bins || live.Objects != want.Objects ||
+			math.Abs(live.Mean-want.Mean) > 1e-9 || math.Abs(live.Variance-want.Variance) > 1e-9 {
+			t.Fatalf("%s: LiveStats() = %+v, BinStats() = %+v", step, live, want)
+		}
+	}
+	check("enable")
+
+	for i := 0; i < 500; i++ {
+		switch rng.Intn(4) {
+		case 0:
+			attach()
+		case 1:
+			p := proxies[rng.Intn(len(proxies))]
+			db.Detach(p)
+		default:
+			p := proxies[rng.Intn(len(proxies))]
+			if p.bin != nil {
+				db.Update(p, rng.Float64()*12-1, rng.Float64()*12-1)
+			}
+		}
+		check(fmt.Sprintf("mutation %d", i))
+	}
+
+	db.Regrid(3, 5)
+	check("Regrid")
+	db.Recenter(8, 8)
+	check("Recenter")
+	db.DetachAll()
+	check("DetachAll")
+
+	db.DisableLiveStats()
+	if got := db.LiveStats(); got != (BinStats{}) {
+		t.Errorf("LiveStats() = %+v after DisableLiveStats, want zero", got)
+	}
+}
+
+func TestTopBins(t *testing.T) {
+	db := NewDB[int](0, 0, 10, 10, 5, 5)
+	id := 0
+	fill := func(x, y float64, n int) {
+		for i := 0; i < n; i++ {
+			db.Attach(id, x, y)
+			id++
+		}
+	}
+	fill(7, 7, 10) // hotspot in bin (3,3)
+	fill(1, 1, 4)
+	fill(5, 1, 4)
+	fill(9, 1, 2)
+	fill(3, 9, 1)
+	fill(20, 20, 50) // the other bin isn't considered
+
+	var tests = []struct {
+		n    int
+		want []BinCount
+	}{
+		{-1, nil},
+		{0, nil},
+		{1, []BinCount{{3, 3, 10}}},
+		{3, []BinCount{{3, 3, 10}, {0, 0, 4}, {2, 0, 4}}},
+		{10, []BinCount{{3, 3, 10}, {0, 0, 4}, {2, 0, 4}, {4, 0, 2}, {1, 4, 1}}},
+	}
+	for _, tt := range tests {
+		got := db.TopBins(tt.n)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("TopBins(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
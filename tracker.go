@@ -0,0 +1,95 @@
+package lq
+
+import "math"
+
+// NearestTracker tracks the object nearest to a moving location, across
+// successive frames during which objects move by a bounded distance.
+//
+// A full search is performed the first time, which also records the distance
+// to the second nearest object. Then, as long as the tracked object is closer
+// to the new location than any other object can possibly be given their
+// maximum displacement, it's returned without searching the database. This
+// amortizes the cost of nearest queries for slowly moving scenes, such as a
+// pursuer chasing its target.
+//
+// The tracker relies on objects moving by at most maxDelta between two
+// successive calls to Nearest. Reset must be called whenever this isn't the
+// case, or when objects are attached to or detached from the database.
+type NearestTracker[T comparable] struct {
+	db       *DB[T]
+	radius   float64
+	maxDelta float64
+	ignored  T
+
+	nearest *Proxy[T] // tracked object, nil if none
+	qx, qy  float64   // location of the last full search
+	bound   float64   // lower bound of the distance from (qx,qy) to other objects
+	moves   int       // number of calls to Nearest since the last full search
+}
+
+// NewNearestTracker returns a tracker of the object of db nearest to a moving
+// location yet within radius, excluding ignored (see FindNearestInRadius).
+//
+// Objects move by at most maxDelta between two calls to Nearest.
+func NewNearestTracker[T comparable](db *DB[T], radius, maxDelta float64, ignored T) *NearestTracker[T] {
+	return &NearestTracker[T]{
+		db:       db,
+		radius:   radius,
+		maxDelta: maxDelta,
+		ignored:  ignored,
+	}
+}
+
+// Nearest returns the object nearest to (x,y) yet within the tracker radius,
+// and true, or the zero value of T and false if there is none. The result is
+// the same as FindNearestInRadius would return.
+func (t *NearestTracker[T]) Nearest(x, y float64) (T, bool) {
+	if t.nearest != nil {
+		t.moves++
+		dist := math.Hypot(x-t.nearest.x, y-t.nearest.y)
+
+		// Other objects were at least bound away from the location of the last
+		// search, the location moved since then, and so did the objects.
+		lower := t.bound - math.Hypot(x-t.qx, y-t.qy) - float64(t.moves)*t.maxDelta
+		if dist < lower {
+			return t.nearest.object, true
+		}
+	}
+	return t.search(x, y)
+}
+
+// search performs a full search and records its result.
+func (t *NearestTracker[T]) search(x, y float64) (T, bool) {
+	var nearest *Proxy[T]
+	sqDist1, sqDist2 := math.MaxFloat64, math.MaxFloat64
+	t.db.forEachProxyWithinRadius(x, y, t.radius, func(cp *Proxy[T], sqDist float64) {
+		if t.db.isIgnored(cp.object, t.ignored) {
+			return
+		}
+		if sqDist < sqDist1 || (sqDist == sqDist1 && cp.seq < nearest.seq) {
+			sqDist2 = sqDist1
+			nearest, sqDist1 = cp, sqDist
+		} else if sqDist < sqDist2 {
+			sqDist2 = sqDist
+		}
+	})
+
+	t.nearest = nearest
+	t.qx, t.qy = x, y
+	t.moves = 0
+	// Objects not found are beyond the radius.
+	t.bound = t.radius
+	if sqDist2 < t.radius*t.radius {
+		t.bound = math.Sqrt(sqDist2)
+	}
+	if nearest == nil {
+		return *new(T), false
+	}
+	return nearest.object, true
+}
+
+// Reset forgets the tracked object, so that the next call to Nearest performs
+// a full search.
+func (t *NearestTracker[T]) Reset() {
+	t.nearest = nil
+}
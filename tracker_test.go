@@ -0,0 +1,86 @@
+package lq
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestNearestTracker(t *testing.T) {
+	t.Run("target changes", func(t *testing.T) {
+		db := NewDB[int](0, 0, 10, 10, 5, 5)
+		db.EnableStats()
+		proxies := map[int]*Proxy[int]{
+			1: db.Attach(1, 2, 5),
+			2: db.Attach(2, 8, 5),
+		}
+		move := func(id int, x, y float64) {
+			db.Update(proxies[id], x, y)
+		}
+		tr := NewNearestTracker(db, 10, 0.5, 0)
+
+		if got, ok := tr.Nearest(4, 5); !ok || got != 1 {
+			t.Fatalf("Nearest() = %v, %t, want 1", got, ok)
+		}
+		// Objects move a bit: 1 is still clearly the nearest, no search needed.
+		move(1, 2.5, 5)
+		move(2, 7.5, 5)
+		if got, ok := tr.Nearest(4, 5); !ok || got != 1 || db.Stats().Queries != 1 {
+			t.Fatalf("Nearest() = %v, %t (%d searches), want 1 (1 search)", got, ok, db.Stats().Queries)
+		}
+		// 2 keeps approaching while 1 flees: the tracked nearest changes. On
+		// frame 1 they're tied, 1 was attached first.
+		want := []int{1, 1, 2, 2, 2}
+		for i, w := range want {
+			move(1, 2-0.5*float64(i), 5)
+			move(2, 7-0.5*float64(i), 5)
+			if got, ok := tr.Nearest(4, 5); !ok || got != w {
+				t.Fatalf("frame %d: Nearest() = %v, %t, want %v", i, got, ok, w)
+			}
+		}
+		// Both leave the radius.
+		tr.Reset()
+		move(1, -20, 5)
+		move(2, 30, 5)
+		if got, ok := tr.Nearest(4, 5); ok {
+			t.Fatalf("Nearest() = %v, %t, want nothing", got, ok)
+		}
+	})
+
+	t.Run("random walk", func(t *testing.T) {
+		const (
+			n        = 100
+			maxDelta = 0.05
+			frames   = 500
+		)
+		rng := rand.New(rand.NewSource(1))
+		db := NewDB[int](0, 0, 10, 10, 10, 10)
+		pos := make([][2]float64, n)
+		proxies := make([]*Proxy[int], n)
+		for i := range pos {
+			pos[i] = [2]float64{rng.Float64() * 10, rng.Float64() * 10}
+			proxies[i] = db.Attach(i, pos[i][0], pos[i][1])
+		}
+		db.EnableStats()
+		// The pursuer is object 0.
+		tr := NewNearestTracker(db, 3, maxDelta, 0)
+		searches := 0
+		for f := 0; f < frames; f++ {
+			for i := range pos {
+				pos[i][0] += (rng.Float64()*2 - 1) * maxDelta / 2
+				pos[i][1] += (rng.Float64()*2 - 1) * maxDelta / 2
+				db.Update(proxies[i], pos[i][0], pos[i][1])
+			}
+			before := db.Stats().Queries
+			got, gotOk := tr.Nearest(pos[0][0], pos[0][1])
+			searches += db.Stats().Queries - before
+			want, wantOk := db.FindNearestInRadius(pos[0][0], pos[0][1], 3, 0)
+			if got != want || gotOk != wantOk {
+				t.Fatalf("frame %d: Nearest() = %v, %t, want %v, %t", f, got, gotOk, want, wantOk)
+			}
+		}
+		if searches >= frames {
+			t.Errorf("%d full searches for %d frames", searches, frames)
+		}
+		t.Logf("%d full searches for %d frames", searches, frames)
+	})
+}